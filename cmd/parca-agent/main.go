@@ -67,6 +67,7 @@ import (
 	"github.com/parca-dev/parca-agent/pkg/analytics"
 	"github.com/parca-dev/parca-agent/pkg/buildinfo"
 	"github.com/parca-dev/parca-agent/pkg/byteorder"
+	"github.com/parca-dev/parca-agent/pkg/cgroup"
 	"github.com/parca-dev/parca-agent/pkg/config"
 	"github.com/parca-dev/parca-agent/pkg/contained"
 	"github.com/parca-dev/parca-agent/pkg/cpuinfo"
@@ -628,6 +629,12 @@ func run(logger log.Logger, reg *prometheus.Registry, flags flags) error {
 		level.Info(logger).Log("msg", "eBPF is supported and enabled by the host kernel")
 	}
 
+	if controllers, err := cgroup.RootControllers(); err != nil {
+		level.Debug(logger).Log("msg", "failed to read the available cgroup2 controllers", "err", err)
+	} else {
+		level.Info(logger).Log("msg", "cgroup2 controllers available", "controllers", strings.Join(controllers, ","))
+	}
+
 	profileStoreClient := agent.NewNoopProfileStoreClient()
 	var debuginfoClient debuginfopb.DebuginfoServiceClient = debuginfo.NewNoopClient()
 
@@ -851,7 +858,9 @@ func run(logger log.Logger, reg *prometheus.Registry, flags flags) error {
 		})
 	}
 
-	ofp := objectfile.NewPool(logger, reg, flags.ObjectFilePool.EvictionPolicy, flags.ObjectFilePool.Size, flags.Profiling.Duration)
+	ofp := objectfile.NewPool(logger, reg, flags.ObjectFilePool.EvictionPolicy, flags.ObjectFilePool.Size, flags.Profiling.Duration,
+		objectfile.WithTracerProvider(tp),
+	)
 	defer ofp.Close() // Will make sure all the files are closed.
 
 	nsCache := namespace.NewCache(logger, reg, flags.Profiling.Duration)
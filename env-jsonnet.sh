@@ -27,3 +27,7 @@ go install "github.com/google/go-jsonnet/cmd/jsonnetfmt@${JSONNET_VERSION}"
 # renovate: datasource=go depName=github.com/jsonnet-bundler/jsonnet-bundler
 JB_VERSION='v0.5.1'
 go install github.com/jsonnet-bundler/jsonnet-bundler/cmd/jb@${JB_VERSION}
+
+# renovate: datasource=go depName=github.com/yannh/kubeconform
+KUBECONFORM_VERSION='v0.6.4'
+go install "github.com/yannh/kubeconform/cmd/kubeconform@${KUBECONFORM_VERSION}"
@@ -252,3 +252,67 @@ func TestFromFile(t *testing.T) {
 		})
 	}
 }
+
+func TestFromNotesAndText(t *testing.T) {
+	f, err := os.Open("./testdata/readelf-sections")
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	ef, err := elf.NewFile(f)
+	require.NoError(t, err)
+
+	got, err := FromNotes(ef)
+	require.NoError(t, err)
+	require.Equal(t, "38485a695f33313366465a4977783952383553352f7061675079616d5137476a525276786b447243682f564636356c4b554450384b684e71766d5133314a2f49765f39585a33486b576a684f57306661525158", got)
+
+	// The synthetic build ID is derived from the .text section only.
+	got, err = FromText(ef)
+	require.NoError(t, err)
+	require.Equal(t, "bd1ca7c3af25af95", got)
+
+	f, err = os.Open("./testdata/missing-text-section")
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	ef, err = elf.NewFile(f)
+	require.NoError(t, err)
+
+	_, err = FromText(ef)
+	require.ErrorIs(t, err, ErrTextSectionNotFound)
+}
+
+func TestSameBinary(t *testing.T) {
+	open := func(path string) IDs {
+		t.Helper()
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { f.Close() })
+		ef, err := elf.NewFile(f)
+		require.NoError(t, err)
+		ids, err := IDsFromELF(ef)
+		require.NoError(t, err)
+		return ids
+	}
+
+	goBin := open("./testdata/readelf-sections")
+	require.Empty(t, goBin.GNU)
+	require.Equal(t, "38485a695f33313366465a4977783952383553352f7061675079616d5137476a525276786b447243682f564636356c4b554450384b684e71766d5133314a2f49765f39585a33486b576a684f57306661525158", goBin.Go)
+	require.Equal(t, "bd1ca7c3af25af95", goBin.Text)
+
+	rust := open("./testdata/rust")
+	require.Equal(t, "ea8a38018312ad155fa70e471d4e0039ff9971c6", rust.GNU)
+	require.Empty(t, rust.Go)
+	require.NotEmpty(t, rust.Text)
+
+	noText := open("./testdata/missing-text-section")
+	require.Empty(t, noText.Text)
+
+	require.True(t, SameBinary(goBin, goBin))
+	require.False(t, SameBinary(goBin, rust))
+	require.False(t, SameBinary(noText, IDs{}))
+
+	// Only the kinds both sides have are compared.
+	require.True(t, SameBinary(goBin, IDs{Go: goBin.Go}))
+	require.True(t, SameBinary(goBin, IDs{Text: goBin.Text}))
+	require.True(t, SameBinary(rust, IDs{GNU: rust.GNU, Text: "other"}))
+	require.False(t, SameBinary(rust, IDs{GNU: "other", Text: rust.Text}))
+	require.False(t, SameBinary(rust, IDs{Go: "other"}))
+}
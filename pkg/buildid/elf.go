@@ -29,8 +29,35 @@ const goBuildIDSectionName = ".note.go.buildid"
 var ErrTextSectionNotFound = errors.New("could not find .text section")
 
 // FromELF returns the build ID for an ELF binary.
+// If the binary does not have a Go or GNU build ID note, the build ID is derived from its contents.
+// See FromText.
 func FromELF(ef *elf.File) (string, error) {
 	// First, try fast methods.
+	if id := fast(ef); id != "" {
+		return id, nil
+	}
+
+	// If that fails, try the slow methods.
+	return buildid(ef)
+}
+
+// FromNotes returns the Go or the GNU build ID of an ELF binary, read from its notes.
+// An empty build ID is returned if the binary does not have any build ID notes.
+func FromNotes(ef *elf.File) (string, error) {
+	if id := fast(ef); id != "" {
+		return id, nil
+	}
+
+	b, err := slowGNU(ef)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// fast returns the Go or the GNU build ID of an ELF binary by searching specific locations.
+// An empty build ID is returned if no build ID is found.
+func fast(ef *elf.File) string {
 	hasGoBuildIDSection := false
 	for _, s := range ef.Sections {
 		if s.Name == goBuildIDSectionName {
@@ -39,15 +66,13 @@ func FromELF(ef *elf.File) (string, error) {
 	}
 	if hasGoBuildIDSection {
 		if id, err := fastGo(ef); err == nil && len(id) > 0 {
-			return hex.EncodeToString(id), nil
+			return hex.EncodeToString(id)
 		}
 	}
 	if id, err := fastGNU(ef); err == nil && len(id) > 0 {
-		return hex.EncodeToString(id), nil
+		return hex.EncodeToString(id)
 	}
-
-	// If that fails, try the slow methods.
-	return buildid(ef)
+	return ""
 }
 
 // buildid returns the build id for an ELF binary by:
@@ -63,6 +88,13 @@ func buildid(ef *elf.File) (string, error) {
 	}
 
 	// If we didn't find a GNU build ID, try hashing the .text section.
+	return FromText(ef)
+}
+
+// FromText returns a synthetic build ID for an ELF binary, by hashing its .text section.
+// It is meant for binaries linked without a build ID note (e.g. with -Wl,--build-id=none).
+// As it is derived from the contents, identical binaries get the same build ID regardless of their paths.
+func FromText(ef *elf.File) (string, error) {
 	text := ef.Section(".text")
 	if text == nil {
 		return "", ErrTextSectionNotFound
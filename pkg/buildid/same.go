@@ -0,0 +1,79 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package buildid
+
+import (
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+)
+
+// IDs are the different kinds of build IDs of a binary, hex encoded like the build IDs returned by FromELF.
+// A binary could have any of them, e.g. Go binaries linked externally have both a Go and a GNU build ID.
+type IDs struct {
+	// GNU is the build ID of the GNU build ID note, if any.
+	GNU string
+	// Go is the build ID of the Go build ID note, if any.
+	Go string
+	// Text is the hash of the .text section, see FromText. It is empty if the binary has no .text section.
+	Text string
+}
+
+// IDsFromELF returns all the kinds of build IDs of an ELF binary, e.g. to compare them with SameBinary.
+// As it hashes the .text section, it is more expensive than FromELF.
+func IDsFromELF(ef *elf.File) (IDs, error) {
+	var ids IDs
+
+	gnu, err := slowGNU(ef)
+	if err != nil {
+		return IDs{}, err
+	}
+	ids.GNU = hex.EncodeToString(gnu)
+
+	// The Go build ID is always in its own section, fastGo fails if there isn't one.
+	if ef.Section(goBuildIDSectionName) != nil {
+		goID, err := fastGo(ef)
+		if err != nil {
+			return IDs{}, err
+		}
+		ids.Go = hex.EncodeToString(goID)
+	}
+
+	ids.Text, err = FromText(ef)
+	if err != nil && !errors.Is(err, ErrTextSectionNotFound) {
+		return IDs{}, err
+	}
+	return ids, nil
+}
+
+// SameBinary reports whether the build IDs of two binaries identify the same binary,
+// even if they have been read from different sources that have different kinds of build IDs.
+// The GNU build IDs are compared if both binaries have one, then the Go build IDs,
+// and, as a fallback, the hashes of the .text sections.
+// The first kind both binaries have decides, e.g. binaries with different GNU build IDs
+// are different even if their .text sections are the same.
+// Binaries that don't have any kind of build ID in common are never the same.
+func SameBinary(a, b IDs) bool {
+	switch {
+	case a.GNU != "" && b.GNU != "":
+		return a.GNU == b.GNU
+	case a.Go != "" && b.Go != "":
+		return a.Go == b.Go
+	case a.Text != "" && b.Text != "":
+		return a.Text == b.Text
+	default:
+		return false
+	}
+}
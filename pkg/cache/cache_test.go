@@ -158,3 +158,61 @@ func TestLRUCacheWithEvictionTTL(t *testing.T) {
 		t.Errorf("expected key3 to be evicted, but was still present")
 	}
 }
+
+func TestCacheWithEvictionReasonTTL(t *testing.T) {
+	for name, newCache := range map[string]func(prometheus.Registerer, int, time.Duration, func(string, int, EvictionReason)) *CacheWithEvictionTTL[string, int]{
+		"lru": NewLRUCacheWithEvictionReasonTTL[string, int],
+		"lfu": NewLFUCacheWithEvictionReasonTTL[string, int],
+	} {
+		t.Run(name, func(t *testing.T) {
+			reasons := map[string]EvictionReason{}
+			c := newCache(prometheus.NewRegistry(), 2, time.Minute, func(key string, value int, reason EvictionReason) {
+				reasons[key] = reason
+			})
+
+			c.Add("key1", 1)
+			c.Add("key2", 2)
+			c.Add("key3", 3)
+			require.Len(t, reasons, 1)
+			for _, reason := range reasons {
+				require.Equal(t, EvictionReasonSize, reason)
+			}
+
+			c.Remove("key3")
+			require.Equal(t, EvictionReasonRemoved, reasons["key3"])
+			clear(reasons)
+			c.Evict()
+			require.Len(t, reasons, 1)
+			for _, reason := range reasons {
+				require.Equal(t, EvictionReasonSize, reason)
+			}
+
+			c.Add("key6", 6)
+			c.Purge()
+			require.Equal(t, EvictionReasonPurged, reasons["key6"])
+
+			// The entries evicted to make room are reported as expired, if they have already expired.
+			clear(reasons)
+			c.ttl = -time.Second
+			c.Add("key7", 7)
+			c.Add("key8", 8)
+			c.Add("key9", 9)
+			require.Len(t, reasons, 1)
+			for _, reason := range reasons {
+				require.Equal(t, EvictionReasonExpired, reason)
+			}
+			c.Purge()
+			c.ttl = time.Minute
+
+			c.Add("key4", 4)
+			c.ttl = -time.Second
+			c.Add("key5", 5)
+			_, ok := c.Get("key5")
+			require.False(t, ok)
+			require.Equal(t, EvictionReasonExpired, reasons["key5"])
+
+			require.NoError(t, c.Close())
+			require.Equal(t, EvictionReasonPurged, reasons["key4"])
+		})
+	}
+}
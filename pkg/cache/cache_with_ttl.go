@@ -87,6 +87,7 @@ func NewLFUCacheWithTTL[K comparable, V any](reg prometheus.Registerer, maxEntri
 type cacherWithRemoveMatching[K comparable, V any] interface {
 	cacher[K, V]
 	RemoveMatching(predicate func(key K, value V) bool)
+	Evict()
 }
 
 type CacheWithTTLOptions struct {
@@ -168,34 +169,76 @@ func (c *CacheWithTTL[K, V]) Close() error {
 	return c.c.Close()
 }
 
+// EvictionReason is the cause of the removal of an entry from a CacheWithEvictionTTL.
+type EvictionReason string
+
+const (
+	// EvictionReasonSize is used when the entry is evicted to make room for a new one.
+	EvictionReasonSize EvictionReason = "size"
+	// EvictionReasonExpired is used when the entry is found to be expired.
+	EvictionReasonExpired EvictionReason = "expired"
+	// EvictionReasonRemoved is used when the entry is removed explicitly.
+	EvictionReasonRemoved EvictionReason = "removed"
+	// EvictionReasonPurged is used when the cache is purged or closed.
+	EvictionReasonPurged EvictionReason = "purged"
+)
+
 // NewLRUCacheWithEvictionTTL returns a new concurrency-safe fixed size cache with LRU exiction policy, TTL and eviction callback.
 func NewLRUCacheWithEvictionTTL[K comparable, V any](reg prometheus.Registerer, maxEntries int, ttl time.Duration, onEvictedCallback func(k K, v V)) *CacheWithEvictionTTL[K, V] {
+	return NewLRUCacheWithEvictionReasonTTL[K, V](reg, maxEntries, ttl, func(k K, v V, _ EvictionReason) {
+		onEvictedCallback(k, v)
+	})
+}
+
+// NewLRUCacheWithEvictionReasonTTL is like NewLRUCacheWithEvictionTTL, but the eviction callback is passed the reason of the eviction.
+func NewLRUCacheWithEvictionReasonTTL[K comparable, V any](reg prometheus.Registerer, maxEntries int, ttl time.Duration, onEvictedCallback func(k K, v V, reason EvictionReason)) *CacheWithEvictionTTL[K, V] {
+	c := &CacheWithEvictionTTL[K, V]{
+		mtx: &sync.RWMutex{},
+		ttl: ttl,
+	}
 	opts := []lru.Option[K, valueWithDeadline[V]]{
 		lru.WithMaxSize[K, valueWithDeadline[V]](maxEntries),
 		lru.WithOnEvict[K, valueWithDeadline[V]](func(k K, vd valueWithDeadline[V]) {
-			onEvictedCallback(k, vd.value)
+			// Happens inside a lock, so we don't need to lock here.
+			onEvictedCallback(k, vd.value, c.evictionReason(vd))
 		}),
 	}
-	return &CacheWithEvictionTTL[K, V]{
-		c:   lru.New[K, valueWithDeadline[V]](reg, opts...),
-		mtx: &sync.RWMutex{},
-		ttl: ttl,
-	}
+	c.c = lru.New[K, valueWithDeadline[V]](reg, opts...)
+	return c
 }
 
 // NewLFUCacheWithEvictionTTL returns a new concurrency-safe fixed size cache with LFU exiction policy, TTL and eviction callback.
 func NewLFUCacheWithEvictionTTL[K comparable, V any](reg prometheus.Registerer, maxEntries int, ttl time.Duration, onEvictedCallback func(k K, v V)) *CacheWithEvictionTTL[K, V] {
+	return NewLFUCacheWithEvictionReasonTTL[K, V](reg, maxEntries, ttl, func(k K, v V, _ EvictionReason) {
+		onEvictedCallback(k, v)
+	})
+}
+
+// NewLFUCacheWithEvictionReasonTTL is like NewLFUCacheWithEvictionTTL, but the eviction callback is passed the reason of the eviction.
+func NewLFUCacheWithEvictionReasonTTL[K comparable, V any](reg prometheus.Registerer, maxEntries int, ttl time.Duration, onEvictedCallback func(k K, v V, reason EvictionReason)) *CacheWithEvictionTTL[K, V] {
+	c := &CacheWithEvictionTTL[K, V]{
+		mtx: &sync.RWMutex{},
+		ttl: ttl,
+	}
 	opts := []lfu.Option[K, valueWithDeadline[V]]{
 		lfu.WithMaxSize[K, valueWithDeadline[V]](maxEntries),
 		lfu.WithOnEvict[K, valueWithDeadline[V]](func(k K, vd valueWithDeadline[V]) {
-			onEvictedCallback(k, vd.value)
+			// Happens inside a lock, so we don't need to lock here.
+			onEvictedCallback(k, vd.value, c.evictionReason(vd))
 		}),
 	}
-	return &CacheWithEvictionTTL[K, V]{
-		c:   lfu.New[K, valueWithDeadline[V]](reg, opts...),
-		mtx: &sync.RWMutex{},
-		ttl: ttl,
+	c.c = lfu.New[K, valueWithDeadline[V]](reg, opts...)
+	return c
+}
+
+// evictionReason returns the reason of the eviction of the given value, caused by the operation in progress.
+// The values that are evicted to make room, but have already expired, are reported as expired.
+// It must be called with mtx held.
+func (c *CacheWithEvictionTTL[K, V]) evictionReason(vd valueWithDeadline[V]) EvictionReason {
+	if c.reason == EvictionReasonSize && vd.deadline.Before(time.Now()) {
+		return EvictionReasonExpired
 	}
+	return c.reason
 }
 
 type CacheWithEvictionTTL[K comparable, V any] struct {
@@ -203,11 +246,14 @@ type CacheWithEvictionTTL[K comparable, V any] struct {
 	mtx *sync.RWMutex
 
 	ttl time.Duration
+	// Reason of the evictions caused by the operation in progress, guarded by mtx.
+	reason EvictionReason
 }
 
 func (c *CacheWithEvictionTTL[K, V]) Add(key K, value V) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
+	c.reason = EvictionReasonSize
 	c.c.Add(key, valueWithDeadline[V]{
 		value:    value,
 		deadline: time.Now().Add(c.ttl),
@@ -224,6 +270,7 @@ func (c *CacheWithEvictionTTL[K, V]) Get(key K) (V, bool) {
 	}
 	if v.deadline.Before(time.Now()) {
 		c.mtx.Lock()
+		c.reason = EvictionReasonExpired
 		c.c.Remove(key)
 		c.mtx.Unlock()
 		var zero V
@@ -242,18 +289,30 @@ func (c *CacheWithEvictionTTL[K, V]) Peek(key K) (V, bool) {
 func (c *CacheWithEvictionTTL[K, V]) Remove(key K) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
+	c.reason = EvictionReasonRemoved
 	c.c.Remove(key)
 }
 
+// Evict evicts one entry from the cache depending on the eviction policy,
+// e.g. the least recently used one, to make room for other resources.
+func (c *CacheWithEvictionTTL[K, V]) Evict() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.reason = EvictionReasonSize
+	c.c.Evict()
+}
+
 func (c *CacheWithEvictionTTL[K, V]) Purge() {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
+	c.reason = EvictionReasonPurged
 	c.c.Purge()
 }
 
 func (c *CacheWithEvictionTTL[K, V]) Close() error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
+	c.reason = EvictionReasonPurged
 
 	return c.c.Close()
 }
@@ -18,14 +18,15 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"unsafe"
 
 	"github.com/prometheus/procfs"
+	"golang.org/x/sys/unix"
 )
 
 /*
@@ -36,6 +37,7 @@ import (
 #include <sys/stat.h>
 #include <fcntl.h>
 #include <stdint.h>
+#include <errno.h>
 
 struct cgid_file_handle
 {
@@ -51,6 +53,7 @@ uint64_t get_cgroupid(char *path) {
   int err;
   uint64_t ret;
 
+  errno = 0;
   h = malloc(sizeof(struct cgid_file_handle));
   if (!h)
     return 0;
@@ -58,12 +61,15 @@ uint64_t get_cgroupid(char *path) {
   h->handle_bytes = 8;
   err = name_to_handle_at(AT_FDCWD, path, (struct file_handle *)h, &mount_id, 0);
   if (err != 0) {
+    err = errno;
     free(h);
+    errno = err;
     return 0;
   }
 
   if (h->handle_bytes != 8) {
     free(h);
+    errno = EOPNOTSUPP;
     return 0;
   }
 
@@ -77,19 +83,28 @@ import "C"
 
 // FindContainerGroup returns the cgroup with the cpu controller or first systemd slice cgroup.
 func FindContainerGroup(cgroups []procfs.Cgroup) procfs.Cgroup {
+	return FindFirstController(cgroups, "cpu")
+}
+
+// FindFirstController returns the first cgroup with the given controller.
+// If there is none, it falls back to the first systemd slice cgroup
+// and then to the first cgroup of the systemd named hierarchy.
+func FindFirstController(cgroups []procfs.Cgroup, controller string) procfs.Cgroup {
 	// If only 1 cgroup, simply return it
 	if len(cgroups) == 1 {
 		return cgroups[0]
 	}
 
+	// Find first cgroup v1 with the controller
 	for _, cg := range cgroups {
-		// Find first cgroup v1 with cpu controller
 		for _, ctlr := range cg.Controllers {
-			if ctlr == "cpu" {
+			if ctlr == controller {
 				return cg
 			}
 		}
+	}
 
+	for _, cg := range cgroups {
 		// Find first systemd slice
 		// https://systemd.io/CGROUP_DELEGATION/#systemds-unit-types
 		if strings.HasPrefix(cg.Path, "/system.slice/") || strings.HasPrefix(cg.Path, "/user.slice/") {
@@ -109,61 +124,237 @@ func FindContainerGroup(cgroups []procfs.Cgroup) procfs.Cgroup {
 }
 
 // PathV2AddMountpoint adds the cgroup2 mountpoint to a path.
+// It returns an error if the path does not exist under any of the known mountpoints,
+// or if it cannot be accessed (e.g. due to insufficient permissions).
 func PathV2AddMountpoint(path string) (string, error) {
-	pathWithMountpoint := filepath.Join("/sys/fs/cgroup/unified", path)
-	if _, err := os.Stat(pathWithMountpoint); os.IsNotExist(err) || errors.Is(err, fs.ErrNotExist) {
-		pathWithMountpoint = filepath.Join("/sys/fs/cgroup", path)
-		if _, err := os.Stat(pathWithMountpoint); os.IsNotExist(err) || errors.Is(err, fs.ErrNotExist) {
-			return "", fmt.Errorf("cannot access cgroup %q: %w", path, err)
+	return defaultFS.PathV2AddMountpoint(path)
+}
+
+// PathV2AddMountpoint is like the package level PathV2AddMountpoint, but reads from the file system of f.
+func (f *FS) PathV2AddMountpoint(path string) (string, error) {
+	for _, mountpoint := range []string{"/sys/fs/cgroup/unified", "/sys/fs/cgroup"} {
+		pathWithMountpoint := filepath.Join(mountpoint, path)
+		_, err := fs.Stat(f.fsys, rel(pathWithMountpoint))
+		if err == nil {
+			return pathWithMountpoint, nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
 		}
+		// Do not silently fall back to the next mountpoint, it would mis-detect the cgroup.
+		return "", fmt.Errorf("cannot access cgroup %q: %w", pathWithMountpoint, err)
 	}
-	return pathWithMountpoint, nil
+	return "", fmt.Errorf("cannot access cgroup %q: %w", path, fs.ErrNotExist)
+}
+
+// PathV1AddMountpoint adds the mountpoint of the cgroup1 hierarchy the given controller is bound to, to a path,
+// as discovered from the mount table, e.g. "/docker/abc" becomes "/sys/fs/cgroup/cpu,cpuacct/docker/abc" for cpuacct.
+// It returns ErrControllerNotEnabled if the controller is not mounted.
+func PathV1AddMountpoint(controller, path string) (string, error) {
+	return defaultFS.PathV1AddMountpoint(controller, path)
 }
 
+// PathV1AddMountpoint is like the package level PathV1AddMountpoint, but reads from the file system of f.
+func (f *FS) PathV1AddMountpoint(controller, path string) (string, error) {
+	mountpoint, err := f.V1Mountpoint(controller)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(mountpoint, path), nil
+}
+
+// ErrHandleUnsupported is returned when the cgroup ID of a path can't be resolved,
+// neither from its file handle nor from its inode number.
+var ErrHandleUnsupported = errors.New("cgroup ID can't be resolved from a file handle or an inode")
+
 // ID returns the cgroup2 ID of a path.
+// The ID is read from the file handle of the path, which is what the kernel reports, e.g. with bpf_get_current_cgroup_id.
+// If file handles are not supported (e.g. on some overlay or namespaced setups), it falls back to
+// the inode number of the path, which is the same as the cgroup ID for cgroup2 on 64-bit kernels.
 func ID(pathWithMountpoint string) (uint64, error) {
 	cPathWithMountpoint := C.CString(pathWithMountpoint)
-	ret := uint64(C.get_cgroupid(cPathWithMountpoint))
+	ret, err := C.get_cgroupid(cPathWithMountpoint)
 	C.free(unsafe.Pointer(cPathWithMountpoint))
-	if ret == 0 {
-		return 0, fmt.Errorf("GetCgroupID on %q failed", pathWithMountpoint)
+	if ret != 0 {
+		return uint64(ret), nil
+	}
+	if err != nil && !handleUnsupported(err) {
+		// e.g. fs.ErrNotExist, if the cgroup has been removed.
+		return 0, fmt.Errorf("GetCgroupID on %q failed: %w", pathWithMountpoint, err)
+	}
+
+	id, err := inodeID(pathWithMountpoint)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("GetCgroupID on %q failed: %w", pathWithMountpoint, err)
+		}
+		return 0, fmt.Errorf("GetCgroupID on %q failed: %w", pathWithMountpoint, errors.Join(ErrHandleUnsupported, err))
 	}
-	return ret, nil
+	return id, nil
+}
+
+// handleUnsupported reports whether the error of get_cgroupid means that the file handles of the path
+// can't be used as cgroup IDs, e.g. they are not supported by the file system or have a different size.
+func handleUnsupported(err error) bool {
+	return errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EOVERFLOW)
+}
+
+// inodeID returns the inode number of a cgroup2 path, which is the cgroup ID since Linux 5.5,
+// where the kernfs node IDs were made 64-bit and the same as the inode numbers.
+func inodeID(pathWithMountpoint string) (uint64, error) {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(pathWithMountpoint, &statfs); err != nil {
+		return 0, fmt.Errorf("failed to statfs %q: %w", pathWithMountpoint, err)
+	}
+	if statfs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return 0, fmt.Errorf("%q is not on a cgroup2 file system", pathWithMountpoint)
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(pathWithMountpoint, &st); err != nil {
+		return 0, fmt.Errorf("failed to stat %q: %w", pathWithMountpoint, err)
+	}
+	return st.Ino, nil
+}
+
+// NormalizeCgroupPath returns the canonical form of a cgroup path, e.g. to be used as a map key
+// or to be matched against the paths found by walking the cgroup file system.
+// It unescapes systemd hex escapes (e.g. \x2d for -), collapses duplicate slashes
+// and removes the trailing slash, except for the root cgroup.
+// The returned path is always absolute.
+func NormalizeCgroupPath(path string) string {
+	return filepath.Clean("/" + unescapeSystemd(path))
+}
+
+// unescapeSystemd unescapes the \xNN escapes of systemd unit names.
+// See https://www.freedesktop.org/software/systemd/man/systemd.unit.html#String%20Escaping%20for%20Inclusion%20in%20Unit%20Names.
+// Malformed escapes are kept as they are.
+func unescapeSystemd(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// SystemdUnit returns the name of the innermost systemd unit of a cgroup path, e.g. nginx.service for
+// /system.slice/nginx.service, or session-3.scope for /user.slice/user-1000.slice/session-3.scope,
+// with its escapes unescaped. Cgroups nested in a unit, e.g. by a service that manages its own cgroups, belong to the unit.
+// It reports whether the unit is a scope, and returns ok=false if the path isn't in a service, scope or slice unit.
+func SystemdUnit(path string) (unit string, scope, ok bool) { //nolint:nonamedreturns
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		name := unescapeSystemd(segments[i])
+		switch {
+		case strings.HasSuffix(name, ".scope"):
+			return name, true, true
+		case strings.HasSuffix(name, ".service"), strings.HasSuffix(name, ".slice"):
+			return name, false, true
+		}
+	}
+	return "", false, false
+}
+
+// CgroupLine is a single line of /proc/[pid]/cgroup.
+// See https://man7.org/linux/man-pages/man7/cgroups.7.html.
+type CgroupLine struct {
+	// HierarchyID is the ID of the cgroup hierarchy. It is 0 for the cgroup2 unified hierarchy.
+	HierarchyID int
+	// Controllers are the controllers bound to the hierarchy. It is empty for the cgroup2 unified hierarchy.
+	Controllers []string
+	// Path is the pathname of the control group relative to the mount point of the hierarchy.
+	Path string
+}
+
+// ParseProcPIDCgroup parses the contents of /proc/[pid]/cgroup.
+func ParseProcPIDCgroup(r io.Reader) ([]CgroupLine, error) {
+	var lines []CgroupLine
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, ":", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed cgroup line %q", text)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed hierarchy ID in cgroup line %q: %w", text, err)
+		}
+		var controllers []string
+		if fields[1] != "" {
+			controllers = strings.Split(fields[1], ",")
+		}
+		lines = append(lines, CgroupLine{
+			HierarchyID: id,
+			Controllers: controllers,
+			Path:        fields[2],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cgroup lines: %w", err)
+	}
+	return lines, nil
 }
 
 // Paths returns the cgroup1 and cgroup2 paths of a process.
 // It does not include the "/sys/fs/cgroup/{unified,systemd,}" prefix.
 func Paths(pid int) (string, string, error) {
-	cgroupPathV1 := ""
-	cgroupPathV2 := ""
-	if cgroupFile, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "cgroup")); err == nil {
-		defer cgroupFile.Close()
-
-		reader := bufio.NewReader(cgroupFile)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				break
-			}
-			// Fallback in case the system the agent is running on doesn't run systemd
-			if strings.Contains(line, ":perf_event:") {
-				cgroupPathV1 = strings.SplitN(line, ":", 3)[2]
-				cgroupPathV1 = strings.TrimSuffix(cgroupPathV1, "\n")
-				continue
-			}
-			if strings.HasPrefix(line, "1:name=systemd:") {
-				cgroupPathV1 = strings.TrimPrefix(line, "1:name=systemd:")
-				cgroupPathV1 = strings.TrimSuffix(cgroupPathV1, "\n")
-				continue
-			}
-			if strings.HasPrefix(line, "0::") {
-				cgroupPathV2 = strings.TrimPrefix(line, "0::")
-				cgroupPathV2 = strings.TrimSuffix(cgroupPathV2, "\n")
-				continue
+	return defaultFS.Paths(pid)
+}
+
+// Paths is like the package level Paths, but reads from the file system of f.
+func (f *FS) Paths(pid int) (string, string, error) {
+	cgroupFile, err := f.fsys.Open(filepath.Join("proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse cgroup: %w", err)
+	}
+	defer cgroupFile.Close()
+
+	lines, err := ParseProcPIDCgroup(cgroupFile)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse cgroup: %w", err)
+	}
+	return paths(lines)
+}
+
+func paths(lines []CgroupLine) (string, string, error) {
+	var (
+		cgroupPathV1    string
+		perfEventPathV1 string
+		cgroupPathV2    string
+	)
+	for _, line := range lines {
+		if line.HierarchyID == 0 && len(line.Controllers) == 0 {
+			cgroupPathV2 = line.Path
+			continue
+		}
+		for _, ctrl := range line.Controllers {
+			switch ctrl {
+			case "name=systemd":
+				cgroupPathV1 = line.Path
+			case "perf_event":
+				perfEventPathV1 = line.Path
 			}
 		}
-	} else {
-		return "", "", fmt.Errorf("cannot parse cgroup: %w", err)
+	}
+	// Fallback in case the system the agent is running on doesn't run systemd.
+	if cgroupPathV1 == "" {
+		cgroupPathV1 = perfEventPathV1
 	}
 
 	if cgroupPathV1 == "/" {
@@ -15,10 +15,15 @@
 package cgroup
 
 import (
+	"io/fs"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/prometheus/procfs"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func TestFindFirstCPUCgroup(t *testing.T) {
@@ -199,3 +204,320 @@ func TestFindFirstCPUCgroup(t *testing.T) {
 		})
 	}
 }
+
+func TestFindFirstController(t *testing.T) {
+	cgroups := []procfs.Cgroup{
+		{HierarchyID: 1, Controllers: []string{"name=systemd"}, Path: "/system.slice/containerd.service"},
+		{HierarchyID: 2, Controllers: []string{"pids"}, Path: "/kubepods/besteffort/pod1ff39434/a"},
+		{HierarchyID: 3, Controllers: []string{"memory"}, Path: "/kubepods/besteffort/pod1ff39434/a"},
+	}
+
+	require.Equal(t, cgroups[2], FindFirstController(cgroups, "memory"))
+	require.Equal(t, cgroups[1], FindFirstController(cgroups, "pids"))
+	// The systemd slice is only used as a last resort.
+	require.Equal(t, cgroups[0], FindFirstController(cgroups, "cpu"))
+	require.Equal(t, procfs.Cgroup{}, FindFirstController(cgroups[1:], "cpu"))
+}
+
+const (
+	procPIDCgroupV1 = `12:perf_event:/kubepods/burstable/pod1ff39434/a
+11:cpu,cpuacct:/kubepods/burstable/pod1ff39434/a
+1:name=systemd:/kubepods/burstable/pod1ff39434/a
+`
+	procPIDCgroupV2 = `0::/system.slice/containerd.service
+`
+	procPIDCgroupHybrid = `12:perf_event:/
+11:cpu,cpuacct:/user.slice
+1:name=systemd:/user.slice/user-1000.slice/session-3.scope
+0::/user.slice/user-1000.slice/session-3.scope
+`
+)
+
+func TestParseProcPIDCgroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []CgroupLine
+		wantErr  bool
+	}{
+		{
+			name:     "cgroup v1 only",
+			contents: procPIDCgroupV1,
+			want: []CgroupLine{
+				{HierarchyID: 12, Controllers: []string{"perf_event"}, Path: "/kubepods/burstable/pod1ff39434/a"},
+				{HierarchyID: 11, Controllers: []string{"cpu", "cpuacct"}, Path: "/kubepods/burstable/pod1ff39434/a"},
+				{HierarchyID: 1, Controllers: []string{"name=systemd"}, Path: "/kubepods/burstable/pod1ff39434/a"},
+			},
+		},
+		{
+			name:     "cgroup v2 only",
+			contents: procPIDCgroupV2,
+			want: []CgroupLine{
+				{HierarchyID: 0, Path: "/system.slice/containerd.service"},
+			},
+		},
+		{
+			name:     "hybrid",
+			contents: procPIDCgroupHybrid,
+			want: []CgroupLine{
+				{HierarchyID: 12, Controllers: []string{"perf_event"}, Path: "/"},
+				{HierarchyID: 11, Controllers: []string{"cpu", "cpuacct"}, Path: "/user.slice"},
+				{HierarchyID: 1, Controllers: []string{"name=systemd"}, Path: "/user.slice/user-1000.slice/session-3.scope"},
+				{HierarchyID: 0, Path: "/user.slice/user-1000.slice/session-3.scope"},
+			},
+		},
+		{
+			name:     "path with colon",
+			contents: "0::/foo:bar\n",
+			want: []CgroupLine{
+				{HierarchyID: 0, Path: "/foo:bar"},
+			},
+		},
+		{
+			name:     "missing fields",
+			contents: "0:/\n",
+			wantErr:  true,
+		},
+		{
+			name:     "malformed hierarchy ID",
+			contents: "x::/\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProcPIDCgroup(strings.NewReader(tt.contents))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantV1   string
+		wantV2   string
+		wantErr  bool
+	}{
+		{
+			name:     "cgroup v1 only",
+			contents: procPIDCgroupV1,
+			wantV1:   "/kubepods/burstable/pod1ff39434/a",
+		},
+		{
+			name:     "cgroup v2 only",
+			contents: procPIDCgroupV2,
+			wantV2:   "/system.slice/containerd.service",
+		},
+		{
+			name:     "hybrid",
+			contents: procPIDCgroupHybrid,
+			wantV1:   "/user.slice/user-1000.slice/session-3.scope",
+			wantV2:   "/user.slice/user-1000.slice/session-3.scope",
+		},
+		{
+			name:     "perf_event fallback without systemd",
+			contents: "12:perf_event:/docker/a\n11:cpu,cpuacct:/docker/a\n",
+			wantV1:   "/docker/a",
+		},
+		{
+			name:     "root cgroup only",
+			contents: "0::/\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cgroupFS := NewFS(fstest.MapFS{
+				"proc/123/cgroup": &fstest.MapFile{Data: []byte(tt.contents)},
+			})
+
+			gotV1, gotV2, err := cgroupFS.Paths(123)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantV1, gotV1)
+			require.Equal(t, tt.wantV2, gotV2)
+		})
+	}
+}
+
+func TestPathV2AddMountpoint(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/unified/system.slice/containerd.service": &fstest.MapFile{Mode: fs.ModeDir},
+		"sys/fs/cgroup/user.slice":                              &fstest.MapFile{Mode: fs.ModeDir},
+	})
+
+	got, err := cgroupFS.PathV2AddMountpoint("/system.slice/containerd.service")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/unified/system.slice/containerd.service", got)
+
+	got, err = cgroupFS.PathV2AddMountpoint("/user.slice")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/user.slice", got)
+
+	_, err = cgroupFS.PathV2AddMountpoint("/missing.slice")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestPathV1AddMountpoint(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte(mountInfoV1)},
+	})
+
+	got, err := cgroupFS.PathV1AddMountpoint("cpuacct", "/docker/a")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/cpu,cpuacct/docker/a", got)
+
+	got, err = cgroupFS.PathV1AddMountpoint("memory", "/")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/memory", got)
+
+	_, err = cgroupFS.PathV1AddMountpoint("cpuset", "/docker/a")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+}
+
+func TestSystemdUnit(t *testing.T) {
+	tests := []struct {
+		path      string
+		wantUnit  string
+		wantScope bool
+		wantOK    bool
+	}{
+		{path: "/system.slice/nginx.service", wantUnit: "nginx.service", wantOK: true},
+		{path: "/user.slice/user-1000.slice/session-3.scope", wantUnit: "session-3.scope", wantScope: true, wantOK: true},
+		{path: "/system.slice", wantUnit: "system.slice", wantOK: true},
+		{path: `/system.slice/system-getty.slice/getty@tty1.service`, wantUnit: "getty@tty1.service", wantOK: true},
+		{path: `/system.slice/system-systemd\x2dfsck.slice/systemd-fsck@dev-disk-by\x2duuid.service`, wantUnit: "systemd-fsck@dev-disk-by-uuid.service", wantOK: true},
+		{path: "/system.slice/containerd.service/runtime/worker", wantUnit: "containerd.service", wantOK: true},
+		{path: "/sys/fs/cgroup/system.slice/docker-" + containerID + ".scope", wantUnit: "docker-" + containerID + ".scope", wantScope: true, wantOK: true},
+		{path: "/docker/" + containerID},
+		{path: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			unit, scope, ok := SystemdUnit(tt.path)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantUnit, unit)
+			require.Equal(t, tt.wantScope, scope)
+		})
+	}
+}
+
+func TestInodeID(t *testing.T) {
+	var path string
+	for _, mountpoint := range []string{"/sys/fs/cgroup/unified", "/sys/fs/cgroup"} {
+		var statfs unix.Statfs_t
+		if err := unix.Statfs(mountpoint, &statfs); err == nil && statfs.Type == unix.CGROUP2_SUPER_MAGIC {
+			path = mountpoint
+			break
+		}
+	}
+	if path == "" {
+		t.Skip("cgroup2 is not mounted")
+	}
+
+	id, err := inodeID(path)
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	// Both methods resolve the same ID, when file handles are supported.
+	if want, err := ID(path); err == nil {
+		require.Equal(t, want, id)
+	}
+
+	_, err = inodeID(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestIDRemoved(t *testing.T) {
+	// A cgroup removed in between is reported as such, not as unsupported.
+	_, err := ID(filepath.Join(t.TempDir(), "removed"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NotErrorIs(t, err, ErrHandleUnsupported)
+}
+
+func TestNormalizeCgroupPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/", want: "/"},
+		{path: "", want: "/"},
+		{path: "/system.slice/foo\\x2dbar.service", want: "/system.slice/foo-bar.service"},
+		{path: "system.slice//foo\\x2dbar.service/", want: "/system.slice/foo-bar.service"},
+		{path: "/system.slice/system-getty.slice/getty@tty1.service", want: "/system.slice/system-getty.slice/getty@tty1.service"},
+		{path: "/system.slice/mnt-data\\x2d1.mount", want: "/system.slice/mnt-data-1.mount"},
+		{path: "/kubepods.slice/kubepods-besteffort.slice//", want: "/kubepods.slice/kubepods-besteffort.slice"},
+		// Malformed escapes are kept.
+		{path: "/system.slice/foo\\xzz.service", want: "/system.slice/foo\\xzz.service"},
+		{path: "/system.slice/foo\\x2", want: "/system.slice/foo\\x2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.want, NormalizeCgroupPath(tt.path))
+		})
+	}
+}
+
+func FuzzParseProcPIDCgroup(f *testing.F) {
+	for _, contents := range []string{procPIDCgroupV1, procPIDCgroupV2, procPIDCgroupHybrid, "", "0::", "1:cpu"} {
+		f.Add([]byte(contents))
+	}
+
+	f.Fuzz(func(t *testing.T, contents []byte) {
+		// Mustn't panic.
+		lines, err := ParseProcPIDCgroup(strings.NewReader(string(contents)))
+		if err != nil {
+			return
+		}
+		// Every line has a hierarchy ID and a path, so lines without two colons are garbage.
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSuffix(line, "\r") // As bufio.ScanLines.
+			if line != "" && strings.Count(line, ":") < 2 {
+				t.Fatalf("malformed line %q was accepted", line)
+			}
+		}
+		// Mustn't panic either.
+		_, _, _ = paths(lines)
+	})
+}
+
+func TestProcessCgroup(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"proc/123/cgroup": &fstest.MapFile{Data: []byte(procPIDCgroupHybrid)},
+		"proc/456/cgroup": &fstest.MapFile{Data: []byte(procPIDCgroupV1)},
+		"proc/789/cgroup": &fstest.MapFile{Data: []byte("0::/\n")},
+		"sys/fs/cgroup/unified/user.slice/user-1000.slice/session-3.scope": &fstest.MapFile{Mode: fs.ModeDir},
+	})
+	id := func(path string) (uint64, error) {
+		require.Equal(t, "/sys/fs/cgroup/unified/user.slice/user-1000.slice/session-3.scope", path)
+		return 42, nil
+	}
+
+	got, err := cgroupFS.processCgroup(123, id)
+	require.NoError(t, err)
+	require.Equal(t, ProcessCgroup{
+		V1Path: "/user.slice/user-1000.slice/session-3.scope",
+		V2Path: "/user.slice/user-1000.slice/session-3.scope",
+		ID:     42,
+	}, got)
+
+	got, err = cgroupFS.processCgroup(456, id)
+	require.NoError(t, err)
+	require.Equal(t, ProcessCgroup{V1Path: "/kubepods/burstable/pod1ff39434/a"}, got)
+
+	_, err = cgroupFS.processCgroup(789, id)
+	require.Error(t, err)
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+// ErrStopWalk can be returned by the callback of EachContainer to stop the iteration early.
+var ErrStopWalk = errors.New("stop walk")
+
+// ContainerCgroup is a cgroup that belongs to a container.
+type ContainerCgroup struct {
+	// CgroupID is the cgroup2 ID of the cgroup. It is 0 if it could not be resolved.
+	CgroupID uint64
+	// Path is the path of the cgroup, including the mountpoint.
+	Path string
+	// Runtime is the container runtime that created the cgroup, if it could be detected.
+	Runtime string
+	// ContainerID is the ID of the container.
+	ContainerID string
+	// PodUID is the UID of the Kubernetes pod the container belongs to, if any.
+	PodUID string
+}
+
+var containerRuntimePrefixes = []struct {
+	prefix  string
+	runtime string
+}{
+	// systemd cgroup driver, e.g. cri-containerd-<id>.scope.
+	{prefix: "cri-containerd-", runtime: "containerd"},
+	{prefix: "crio-", runtime: "cri-o"},
+	{prefix: "docker-", runtime: "docker"},
+	{prefix: "libpod-", runtime: "podman"},
+}
+
+var (
+	containerIDRgx = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	podUIDRgx      = regexp.MustCompile(`pod([0-9a-f]{8}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{4}[-_]?[0-9a-f]{12})`)
+)
+
+// ParseContainerCgroup extracts the container runtime, container ID and pod UID from a cgroup path.
+// It supports both the systemd and the cgroupfs cgroup drivers.
+// It returns false if the path does not belong to a container.
+func ParseContainerCgroup(path string) (ContainerCgroup, bool) {
+	return parseContainerCgroup(path, DriverUnknown)
+}
+
+// parseContainerCgroup is like ParseContainerCgroup, but only accepts the paths of the given cgroup driver.
+func parseContainerCgroup(path string, driver Driver) (ContainerCgroup, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 0 {
+		return ContainerCgroup{}, false
+	}
+
+	c := ContainerCgroup{Path: path}
+	last := segments[len(segments)-1]
+	switch {
+	case strings.HasSuffix(last, ".scope") && driver != DriverCgroupfs:
+		name := strings.TrimSuffix(last, ".scope")
+		for _, p := range containerRuntimePrefixes {
+			if strings.HasPrefix(name, p.prefix) {
+				c.Runtime = p.runtime
+				c.ContainerID = strings.TrimPrefix(name, p.prefix)
+				break
+			}
+		}
+	case containerIDRgx.MatchString(last) && driver != DriverSystemd:
+		c.ContainerID = last
+		if len(segments) > 1 {
+			// e.g. /docker/<id>
+			switch segments[len(segments)-2] {
+			case "docker":
+				c.Runtime = "docker"
+			case "libpod_parent":
+				c.Runtime = "podman"
+			}
+		}
+	}
+	if !containerIDRgx.MatchString(c.ContainerID) {
+		return ContainerCgroup{}, false
+	}
+
+	for _, segment := range segments[:len(segments)-1] {
+		if m := podUIDRgx.FindStringSubmatch(segment); m != nil {
+			// The systemd cgroup driver replaces dashes with underscores.
+			c.PodUID = strings.ReplaceAll(m[1], "_", "-")
+		}
+	}
+	return c, true
+}
+
+// EachContainer walks the cgroup hierarchy rooted at rootDir and calls fn
+// for each cgroup that belongs to a container.
+// If fn returns ErrStopWalk, the iteration stops and EachContainer returns nil.
+// Any other error returned by fn stops the iteration and is returned.
+func EachContainer(rootDir string, fn func(ContainerCgroup) error, opts ...WalkOption) error {
+	return defaultFS.EachContainer(rootDir, fn, opts...)
+}
+
+// EachContainer is like the package level EachContainer, but reads from the file system of f.
+func (f *FS) EachContainer(rootDir string, fn func(ContainerCgroup) error, opts ...WalkOption) error {
+	prune := f.pruner(opts)
+	err := fs.WalkDir(f.fsys, rel(rootDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// The cgroup has been removed while walking.
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		path = abs(path)
+		if prune(path) {
+			return fs.SkipDir
+		}
+		c, ok := ParseContainerCgroup(path)
+		if !ok {
+			return nil
+		}
+		if id, err := ID(path); err == nil {
+			c.CgroupID = id
+		}
+		return fn(c)
+	})
+	if errors.Is(err, ErrStopWalk) {
+		return nil
+	}
+	return err
+}
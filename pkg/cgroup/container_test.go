@@ -0,0 +1,169 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+const containerID = "09af509f3db677a2275723fc71bff3d9b6d19e4d404c44822f2262f700adcd4b"
+
+func TestParseContainerCgroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		want   ContainerCgroup
+		wantOK bool
+	}{
+		{
+			name: "systemd driver with containerd",
+			path: "/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope",
+			want: ContainerCgroup{
+				Path:        "/sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope",
+				Runtime:     "containerd",
+				ContainerID: containerID,
+				PodUID:      "1ff39434-b35f-aeef-6415-9d11e3f96024",
+			},
+			wantOK: true,
+		},
+		{
+			name: "systemd driver with static pod",
+			path: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434b35faeef64159d11e3f96024.slice/docker-" + containerID + ".scope",
+			want: ContainerCgroup{
+				Path:        "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434b35faeef64159d11e3f96024.slice/docker-" + containerID + ".scope",
+				Runtime:     "docker",
+				ContainerID: containerID,
+				PodUID:      "1ff39434b35faeef64159d11e3f96024",
+			},
+			wantOK: true,
+		},
+		{
+			name: "cgroupfs driver",
+			path: "/kubepods/besteffort/pod1ff39434-b35f-aeef-6415-9d11e3f96024/" + containerID,
+			want: ContainerCgroup{
+				Path:        "/kubepods/besteffort/pod1ff39434-b35f-aeef-6415-9d11e3f96024/" + containerID,
+				ContainerID: containerID,
+				PodUID:      "1ff39434-b35f-aeef-6415-9d11e3f96024",
+			},
+			wantOK: true,
+		},
+		{
+			name: "plain docker",
+			path: "/docker/" + containerID,
+			want: ContainerCgroup{
+				Path:        "/docker/" + containerID,
+				Runtime:     "docker",
+				ContainerID: containerID,
+			},
+			wantOK: true,
+		},
+		{
+			name: "pod cgroup",
+			path: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice",
+		},
+		{
+			name: "systemd service",
+			path: "/system.slice/containerd.service",
+		},
+		{
+			name: "root",
+			path: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseContainerCgroup(tt.path)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEachContainer(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/system.slice/containerd.service": &fstest.MapFile{Mode: fs.ModeDir},
+		"sys/fs/cgroup/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope": &fstest.MapFile{Mode: fs.ModeDir},
+		"sys/fs/cgroup/docker/" + containerID: &fstest.MapFile{Mode: fs.ModeDir},
+	})
+
+	var got []ContainerCgroup
+	require.NoError(t, cgroupFS.EachContainer("/sys/fs/cgroup", func(c ContainerCgroup) error {
+		got = append(got, c)
+		return nil
+	}))
+	require.Len(t, got, 2)
+	require.Equal(t, "/sys/fs/cgroup/docker/"+containerID, got[0].Path)
+	require.Equal(t, "docker", got[0].Runtime)
+	require.Equal(t, "containerd", got[1].Runtime)
+	require.Equal(t, "1ff39434-b35f-aeef-6415-9d11e3f96024", got[1].PodUID)
+
+	var calls int
+	require.NoError(t, cgroupFS.EachContainer("/sys/fs/cgroup", func(c ContainerCgroup) error {
+		calls++
+		return ErrStopWalk
+	}))
+	require.Equal(t, 1, calls)
+
+	errFoo := errors.New("foo")
+	require.ErrorIs(t, cgroupFS.EachContainer("/sys/fs/cgroup", func(c ContainerCgroup) error {
+		return errFoo
+	}), errFoo)
+}
+
+func TestEachContainerSkipSelf(t *testing.T) {
+	const selfID = "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f809"
+	fsys := fstest.MapFS{
+		"proc/" + strconv.Itoa(os.Getpid()) + "/cgroup": &fstest.MapFile{Data: []byte("0::/docker/" + selfID + "\n")},
+		"sys/fs/cgroup/docker/" + containerID:           &fstest.MapFile{Mode: fs.ModeDir},
+		"sys/fs/cgroup/docker/" + selfID + "/nested":    &fstest.MapFile{Mode: fs.ModeDir},
+	}
+	paths := func(opts ...WalkOption) []string {
+		var got []string
+		require.NoError(t, NewFS(fsys).EachContainer("/sys/fs/cgroup", func(c ContainerCgroup) error {
+			got = append(got, c.Path)
+			return nil
+		}, opts...))
+		return got
+	}
+
+	// Opt-in.
+	require.Equal(t, []string{"/sys/fs/cgroup/docker/" + containerID, "/sys/fs/cgroup/docker/" + selfID}, paths())
+	require.Equal(t, []string{"/sys/fs/cgroup/docker/" + containerID}, paths(SkipSelf()))
+
+	// Nested cgroups that only end with the path of the agent are not pruned,
+	// e.g. of a container running systemd, nor the cgroups of the other hierarchy.
+	nested := "sys/fs/cgroup/docker/" + containerID + "/docker/" + selfID
+	fsys[nested] = &fstest.MapFile{Mode: fs.ModeDir}
+	fsys["sys/fs/cgroup/systemd/docker/"+containerID] = &fstest.MapFile{Mode: fs.ModeDir}
+	fsys["proc/"+strconv.Itoa(os.Getpid())+"/cgroup"] = &fstest.MapFile{Data: []byte(
+		"1:name=systemd:/docker/" + containerID + "\n" +
+			"0::/docker/" + selfID + "\n",
+	)}
+	require.Equal(t, []string{"/sys/fs/cgroup/docker/" + containerID, "/" + nested}, paths(SkipSelf()))
+	delete(fsys, nested)
+	delete(fsys, "sys/fs/cgroup/systemd/docker/"+containerID)
+
+	// The root cgroup, e.g. in a cgroup namespace, is never pruned.
+	fsys["proc/"+strconv.Itoa(os.Getpid())+"/cgroup"] = &fstest.MapFile{Data: []byte("0::/\n")}
+	require.Len(t, paths(SkipSelf()), 2)
+}
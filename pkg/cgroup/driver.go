@@ -0,0 +1,94 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Driver is the cgroup driver the kubelet and the container runtimes use to manage the cgroups.
+// See https://kubernetes.io/docs/setup/production-environment/container-runtimes/#cgroup-drivers.
+type Driver string
+
+const (
+	// DriverUnknown is used when the layout of the hierarchy doesn't tell the driver.
+	// The parsers accept the paths of both drivers then.
+	DriverUnknown Driver = "unknown"
+	// DriverSystemd creates the cgroups as systemd units, e.g. /kubepods.slice/.../cri-containerd-<id>.scope.
+	DriverSystemd Driver = "systemd"
+	// DriverCgroupfs creates the cgroups as plain directories, e.g. /kubepods/burstable/pod<uid>/<id>.
+	DriverCgroupfs Driver = "cgroupfs"
+)
+
+// DetectDriver infers the cgroup driver from the top-level cgroups of the hierarchy.
+// It returns DriverUnknown, and no error, if the hierarchy has neither layout clearly.
+func DetectDriver() (Driver, error) {
+	return defaultFS.DetectDriver()
+}
+
+// DetectDriver is like the package level DetectDriver, but reads from the file system of f.
+func (f *FS) DetectDriver() (Driver, error) {
+	// The cgroup2 hierarchy, in the unified and the hybrid modes, and the named systemd hierarchy in the legacy mode.
+	for _, mountpoint := range []string{"/sys/fs/cgroup/unified", "/sys/fs/cgroup/systemd", "/sys/fs/cgroup"} {
+		entries, err := fs.ReadDir(f.fsys, rel(mountpoint))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return DriverUnknown, fmt.Errorf("failed to read cgroup hierarchy %q: %w", mountpoint, err)
+		}
+		if driver := detectDriver(entries); driver != DriverUnknown {
+			return driver, nil
+		}
+	}
+	return DriverUnknown, nil
+}
+
+func detectDriver(entries []fs.DirEntry) Driver {
+	var slices bool
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		switch name := e.Name(); {
+		case name == "kubepods.slice":
+			return DriverSystemd
+		case name == "kubepods" || name == "docker":
+			// Hosts managed by systemd could still run a kubelet or a runtime with the cgroupfs driver.
+			return DriverCgroupfs
+		case strings.HasSuffix(name, ".slice"):
+			slices = true
+		}
+	}
+	if slices {
+		return DriverSystemd
+	}
+	return DriverUnknown
+}
+
+// matches reports whether the segment of a cgroup path of a slice could have been created by the driver.
+func (d Driver) matches(segment string) bool {
+	switch d { //nolint:exhaustive
+	case DriverSystemd:
+		return strings.HasSuffix(segment, ".slice")
+	case DriverCgroupfs:
+		return !strings.HasSuffix(segment, ".slice")
+	default:
+		return true
+	}
+}
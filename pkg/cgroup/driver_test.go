@@ -0,0 +1,114 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDriver(t *testing.T) {
+	dir := &fstest.MapFile{Mode: fs.ModeDir}
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want Driver
+	}{
+		{
+			name: "systemd",
+			fsys: fstest.MapFS{
+				"sys/fs/cgroup/kubepods.slice": dir,
+				"sys/fs/cgroup/system.slice":   dir,
+			},
+			want: DriverSystemd,
+		},
+		{
+			name: "cgroupfs kubelet on a systemd host",
+			fsys: fstest.MapFS{
+				"sys/fs/cgroup/kubepods":     dir,
+				"sys/fs/cgroup/system.slice": dir,
+			},
+			want: DriverCgroupfs,
+		},
+		{
+			name: "cgroupfs docker in the hybrid mode",
+			fsys: fstest.MapFS{
+				"sys/fs/cgroup/unified/docker":      dir,
+				"sys/fs/cgroup/unified/init.scope":  dir,
+				"sys/fs/cgroup/memory/system.slice": dir,
+			},
+			want: DriverCgroupfs,
+		},
+		{
+			name: "systemd in the legacy mode",
+			fsys: fstest.MapFS{
+				"sys/fs/cgroup/systemd/user.slice": dir,
+				"sys/fs/cgroup/memory/user.slice":  dir,
+			},
+			want: DriverSystemd,
+		},
+		{
+			name: "unknown",
+			fsys: fstest.MapFS{
+				"sys/fs/cgroup/cgroup.procs": &fstest.MapFile{},
+				"sys/fs/cgroup/foo":          dir,
+			},
+			want: DriverUnknown,
+		},
+		{
+			name: "not mounted",
+			fsys: fstest.MapFS{},
+			want: DriverUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFS(tt.fsys).DetectDriver()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClassifyK8sCgroupForDriver(t *testing.T) {
+	const (
+		systemdPath  = "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope"
+		cgroupfsPath = "/kubepods/besteffort/pod1ff39434-b35f-aeef-6415-9d11e3f96024/" + containerID
+	)
+
+	for _, path := range []string{systemdPath, cgroupfsPath} {
+		level, _, id := ClassifyK8sCgroupForDriver(path, DriverUnknown)
+		require.Equal(t, LevelContainer, level, path)
+		require.Equal(t, containerID, id)
+	}
+
+	level, _, _ := ClassifyK8sCgroupForDriver(systemdPath, DriverSystemd)
+	require.Equal(t, LevelContainer, level)
+	level, _, _ = ClassifyK8sCgroupForDriver(systemdPath, DriverCgroupfs)
+	require.Equal(t, LevelUnknown, level)
+
+	level, _, _ = ClassifyK8sCgroupForDriver(cgroupfsPath, DriverCgroupfs)
+	require.Equal(t, LevelContainer, level)
+	level, _, _ = ClassifyK8sCgroupForDriver(cgroupfsPath, DriverSystemd)
+	require.Equal(t, LevelUnknown, level)
+
+	// Mixed layouts are rejected once the driver is known.
+	level, _, _ = ClassifyK8sCgroupForDriver("/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/"+containerID, DriverSystemd)
+	require.Equal(t, LevelUnknown, level)
+}
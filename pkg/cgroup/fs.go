@@ -0,0 +1,52 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// FS reads cgroup information from /proc and /sys of a file system.
+// The package level functions use an FS backed by the host file system.
+type FS struct {
+	fsys fs.FS
+}
+
+// NewFS returns an FS that reads from the given file system, which is expected to be rooted at "/",
+// e.g. a fstest.MapFS with "proc/self/cgroup" and "sys/fs/cgroup/..." entries for tests.
+func NewFS(fsys fs.FS) *FS {
+	return &FS{fsys: fsys}
+}
+
+var defaultFS = NewFS(os.DirFS("/"))
+
+// rel converts an absolute path to a path valid for fs.FS.
+func rel(path string) string {
+	path = strings.TrimLeft(path, "/")
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// abs converts a path valid for fs.FS to an absolute path.
+func abs(path string) string {
+	if path == "." {
+		return "/"
+	}
+	return "/" + path
+}
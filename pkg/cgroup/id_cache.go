@@ -0,0 +1,88 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxCachedIDs bounds the number of paths CachedID keeps the ID of,
+// so the cache doesn't grow with the container churn.
+const maxCachedIDs = 1024
+
+var defaultIDCache = newIDCache(ID)
+
+// CachedID is like ID, but caches the ID of the path, e.g. for the cgroups that are looked up repeatedly.
+// The cached ID is invalidated when the inode or the modification time of the path change,
+// e.g. when the cgroup is removed and created again. Use ID for one-shot lookups.
+func CachedID(pathWithMountpoint string) (uint64, error) {
+	return defaultIDCache.get(pathWithMountpoint)
+}
+
+type idCacheEntry struct {
+	ino   uint64
+	mtime unix.Timespec
+	id    uint64
+}
+
+type idCache struct {
+	// Resolves the cgroup ID of a path, replaced in tests.
+	id func(path string) (uint64, error)
+
+	mtx     *sync.RWMutex
+	entries map[string]idCacheEntry
+}
+
+func newIDCache(id func(path string) (uint64, error)) *idCache {
+	return &idCache{
+		id:      id,
+		mtx:     &sync.RWMutex{},
+		entries: map[string]idCacheEntry{},
+	}
+}
+
+func (c *idCache) get(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, fmt.Errorf("failed to stat cgroup %q: %w", path, err)
+	}
+
+	c.mtx.RLock()
+	e, ok := c.entries[path]
+	c.mtx.RUnlock()
+	if ok && e.ino == st.Ino && e.mtime == st.Mtim {
+		return e.id, nil
+	}
+
+	id, err := c.id(path)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.entries[path]; !ok && len(c.entries) >= maxCachedIDs {
+		// Evict an arbitrary entry, the stable paths are added back on their next lookup.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[path] = idCacheEntry{ino: st.Ino, mtime: st.Mtim, id: id}
+	return id, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDCache(t *testing.T) {
+	var calls uint64
+	c := newIDCache(func(string) (uint64, error) {
+		calls++
+		return calls, nil
+	})
+
+	path := filepath.Join(t.TempDir(), "system.slice")
+	require.NoError(t, os.Mkdir(path, 0o755))
+
+	id, err := c.get(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), id)
+	id, err = c.get(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), id)
+
+	// Modified, e.g. a child cgroup has been created.
+	mtime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+	id, err = c.get(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), id)
+
+	// Removed and created again.
+	require.NoError(t, os.Remove(path))
+	_, err = c.get(path)
+	require.Error(t, err)
+	require.NoError(t, os.Mkdir(path, 0o755))
+	id, err = c.get(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), id)
+}
+
+func BenchmarkID(b *testing.B) {
+	const path = "/sys/fs/cgroup"
+	if _, err := ID(path); err != nil {
+		b.Skipf("cgroup ID of %s can't be resolved: %v", path, err)
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10_000; j++ {
+				_, _ = ID(path)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10_000; j++ {
+				_, _ = CachedID(path)
+			}
+		}
+	})
+}
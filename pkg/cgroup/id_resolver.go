@@ -0,0 +1,192 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCgroupNotFound is returned by CgroupIDResolver when there's no cgroup with the requested ID in the hierarchy,
+// e.g. because it has already been removed.
+var ErrCgroupNotFound = errors.New("cgroup not found")
+
+// CgroupIDResolver resolves the cgroup IDs reported by bpf_get_current_cgroup_id to cgroup paths, and back.
+// Unlike MetadataCache, it doesn't watch the hierarchy: the hierarchy is walked when an ID is not cached,
+// and the IDs of the cached paths are checked to be still the same before they are returned,
+// as cgroups could be removed and created again at the same path, e.g. when a systemd unit restarts.
+type CgroupIDResolver struct {
+	rootDir string
+	// Resolves the cgroup ID of a path, replaced in tests.
+	id func(path string) (uint64, error)
+
+	// Serializes the walks, so concurrent misses don't walk the hierarchy more than once.
+	walkMtx *sync.Mutex
+	// IDs not found by the last walks, by the time they were looked up, guarded by walkMtx.
+	// They are not looked up again for missTTL, so IDs that can't be resolved, e.g. of cgroups
+	// already removed or outside of rootDir, don't walk the hierarchy on every sample.
+	misses  map[uint64]time.Time
+	missTTL time.Duration
+
+	mtx    *sync.RWMutex
+	byID   map[uint64]string
+	byPath map[string]uint64
+}
+
+// defaultMissTTL is how long the IDs not found in the hierarchy are not looked up again.
+const defaultMissTTL = 5 * time.Second
+
+// NewCgroupIDResolver returns a CgroupIDResolver for the cgroup v2 hierarchy mounted at rootDir, e.g. /sys/fs/cgroup.
+func NewCgroupIDResolver(rootDir string) *CgroupIDResolver {
+	return &CgroupIDResolver{
+		rootDir: rootDir,
+		id:      ID,
+
+		walkMtx: &sync.Mutex{},
+		misses:  map[uint64]time.Time{},
+		missTTL: defaultMissTTL,
+
+		mtx:    &sync.RWMutex{},
+		byID:   map[uint64]string{},
+		byPath: map[string]uint64{},
+	}
+}
+
+// Path returns the path, including the mountpoint, of the cgroup with the given ID.
+// It returns ErrCgroupNotFound if there's no such cgroup in the hierarchy.
+func (r *CgroupIDResolver) Path(id uint64) (string, error) {
+	if path, ok := r.cachedPath(id); ok {
+		return path, nil
+	}
+
+	r.walkMtx.Lock()
+	defer r.walkMtx.Unlock()
+
+	// Could have been resolved by a concurrent walk.
+	if path, ok := r.cachedPath(id); ok {
+		return path, nil
+	}
+	now := time.Now()
+	if missedAt, ok := r.misses[id]; ok && now.Sub(missedAt) < r.missTTL {
+		return "", fmt.Errorf("cgroup ID %d: %w", id, ErrCgroupNotFound)
+	}
+	if err := r.walk(); err != nil {
+		return "", err
+	}
+
+	r.mtx.RLock()
+	path, ok := r.byID[id]
+	r.mtx.RUnlock()
+	if ok {
+		delete(r.misses, id)
+		return path, nil
+	}
+	for missed, missedAt := range r.misses {
+		if now.Sub(missedAt) >= r.missTTL {
+			delete(r.misses, missed)
+		}
+	}
+	r.misses[id] = now
+	return "", fmt.Errorf("cgroup ID %d: %w", id, ErrCgroupNotFound)
+}
+
+// ID returns the ID of the cgroup at the given path, including the mountpoint.
+func (r *CgroupIDResolver) ID(pathWithMountpoint string) (uint64, error) {
+	// Resolving the ID is as cheap as checking that the cached one is still the same.
+	id, err := r.id(pathWithMountpoint)
+	if err != nil {
+		r.invalidate(pathWithMountpoint)
+		return 0, err
+	}
+	r.add(id, pathWithMountpoint)
+	return id, nil
+}
+
+// cachedPath returns the cached path of the ID, if the cgroup at the path still has the same ID.
+func (r *CgroupIDResolver) cachedPath(id uint64) (string, bool) {
+	r.mtx.RLock()
+	path, ok := r.byID[id]
+	r.mtx.RUnlock()
+	if !ok {
+		return "", false
+	}
+
+	current, err := r.id(path)
+	if err != nil {
+		// Removed.
+		r.invalidate(path)
+		return "", false
+	}
+	if current != id {
+		// Removed and created again.
+		r.add(current, path)
+		return "", false
+	}
+	return path, true
+}
+
+// walk resolves the IDs of all the cgroups in the hierarchy.
+func (r *CgroupIDResolver) walk() error {
+	return filepath.WalkDir(r.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// The cgroup has been removed while walking.
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		// The known paths are resolved again, as they could have been created again with new IDs.
+		id, err := r.id(path)
+		if err != nil {
+			// Not a cgroup, or removed in between.
+			return nil //nolint:nilerr
+		}
+		r.add(id, path)
+		return nil
+	})
+}
+
+func (r *CgroupIDResolver) add(id uint64, path string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// The cgroup at path could have been created again with a new ID, and the ID reused by another cgroup.
+	if old, ok := r.byPath[path]; ok {
+		delete(r.byID, old)
+	}
+	if old, ok := r.byID[id]; ok {
+		delete(r.byPath, old)
+	}
+	r.byID[id] = path
+	r.byPath[path] = id
+}
+
+func (r *CgroupIDResolver) invalidate(path string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if id, ok := r.byPath[path]; ok {
+		delete(r.byID, id)
+		delete(r.byPath, path)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupIDResolver(t *testing.T) {
+	root := t.TempDir()
+	podPath := filepath.Join(root, "kubepods.slice", "pod1")
+	require.NoError(t, os.MkdirAll(podPath, 0o755))
+
+	ids := map[string]uint64{
+		root:                                  1,
+		filepath.Join(root, "kubepods.slice"): 2,
+		podPath:                               3,
+	}
+	var calls int
+	r := NewCgroupIDResolver(root)
+	r.id = func(path string) (uint64, error) {
+		calls++
+		id, ok := ids[path]
+		if !ok {
+			return 0, os.ErrNotExist
+		}
+		return id, nil
+	}
+
+	path, err := r.Path(3)
+	require.NoError(t, err)
+	require.Equal(t, podPath, path)
+	require.Equal(t, 3, calls)
+
+	// Both directions are cached, only the IDs of the cached paths are checked.
+	path, err = r.Path(2)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "kubepods.slice"), path)
+	id, err := r.ID(podPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), id)
+	require.Equal(t, 5, calls)
+
+	// Unknown IDs walk the hierarchy, but not again right away.
+	_, err = r.Path(4)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+	require.Equal(t, 8, calls)
+	_, err = r.Path(4)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+	require.Equal(t, 8, calls)
+
+	// Removed cgroups are invalidated.
+	require.NoError(t, os.Remove(podPath))
+	delete(ids, podPath)
+	_, err = r.Path(3)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+
+	// Created again with a new ID.
+	require.NoError(t, os.Mkdir(podPath, 0o755))
+	ids[podPath] = 5
+	path, err = r.Path(5)
+	require.NoError(t, err)
+	require.Equal(t, podPath, path)
+	_, err = r.Path(3)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+}
+
+func TestCgroupIDResolverRecreated(t *testing.T) {
+	root := t.TempDir()
+	unitPath := filepath.Join(root, "system.slice", "app.service")
+	require.NoError(t, os.MkdirAll(unitPath, 0o755))
+
+	ids := map[string]uint64{
+		root:                                1,
+		filepath.Join(root, "system.slice"): 2,
+		unitPath:                            3,
+	}
+	r := NewCgroupIDResolver(root)
+	r.id = func(path string) (uint64, error) {
+		id, ok := ids[path]
+		if !ok {
+			return 0, os.ErrNotExist
+		}
+		return id, nil
+	}
+
+	path, err := r.Path(3)
+	require.NoError(t, err)
+	require.Equal(t, unitPath, path)
+
+	// The unit restarts, its cgroup is created again at the same path with a new ID.
+	require.NoError(t, os.Remove(unitPath))
+	require.NoError(t, os.Mkdir(unitPath, 0o755))
+	ids[unitPath] = 4
+
+	// The new ID is resolved, even though the path is already known.
+	path, err = r.Path(4)
+	require.NoError(t, err)
+	require.Equal(t, unitPath, path)
+	_, err = r.Path(3)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+
+	// Once more, with the old ID looked up first.
+	ids[unitPath] = 5
+	_, err = r.Path(4)
+	require.ErrorIs(t, err, ErrCgroupNotFound)
+	path, err = r.Path(5)
+	require.NoError(t, err)
+	require.Equal(t, unitPath, path)
+	id, err := r.ID(unitPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), id)
+}
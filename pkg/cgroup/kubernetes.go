@@ -0,0 +1,123 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"strings"
+)
+
+// Level is the level of the Kubernetes cgroup hierarchy a cgroup belongs to.
+type Level int
+
+const (
+	// LevelUnknown is a cgroup that is not managed by the kubelet.
+	LevelUnknown Level = iota
+	// LevelRoot is the root of the pods of the node, e.g. kubepods.slice.
+	LevelRoot
+	// LevelQoS is the parent of the pods of a QoS class, e.g. kubepods-burstable.slice.
+	// Guaranteed pods are directly under the root.
+	LevelQoS
+	// LevelPod is the cgroup of a pod.
+	LevelPod
+	// LevelContainer is the cgroup of a container of a pod, or a cgroup nested in it.
+	LevelContainer
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelRoot:
+		return "root"
+	case LevelQoS:
+		return "qos"
+	case LevelPod:
+		return "pod"
+	case LevelContainer:
+		return "container"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyK8sCgroup returns the level of the Kubernetes cgroup hierarchy the cgroup at the given path belongs to,
+// with the UID of the pod and the ID of the container, if the path is at or under the pod and the container levels.
+// It supports both the systemd and the cgroupfs cgroup drivers, e.g.:
+//
+//	/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice/cri-containerd-<id>.scope
+//	/kubepods/burstable/pod<uid>/<id>
+//
+// The path could include the mountpoint, or the cgroups the kubelet is nested in, e.g. with kind.
+func ClassifyK8sCgroup(path string) (level Level, podUID, containerID string) { //nolint:nonamedreturns
+	return ClassifyK8sCgroupForDriver(path, DriverUnknown)
+}
+
+// ClassifyK8sCgroupForDriver is like ClassifyK8sCgroup, but only accepts the paths of the given cgroup driver,
+// e.g. as detected by DetectDriver. DriverUnknown accepts the paths of both drivers.
+func ClassifyK8sCgroupForDriver(path string, driver Driver) (level Level, podUID, containerID string) { //nolint:nonamedreturns
+	segments := strings.Split(strings.Trim(NormalizeCgroupPath(path), "/"), "/")
+
+	root := -1
+	for i, segment := range segments {
+		isRoot := segment == "kubepods" || segment == "kubepods.slice" || strings.HasSuffix(segment, "-kubepods.slice")
+		if isRoot && driver.matches(segment) {
+			root = i
+			break
+		}
+	}
+	if root == -1 {
+		return LevelUnknown, "", ""
+	}
+
+	level = LevelRoot
+	for i := root + 1; i < len(segments); i++ {
+		segment := segments[i]
+		switch level { //nolint:exhaustive
+		case LevelRoot, LevelQoS:
+			if !driver.matches(segment) {
+				return LevelUnknown, "", ""
+			}
+			if m := podUIDRgx.FindStringSubmatch(segment); m != nil {
+				// The systemd cgroup driver replaces dashes with underscores.
+				level, podUID = LevelPod, strings.ReplaceAll(m[1], "_", "-")
+				continue
+			}
+			if level == LevelRoot && isQoSCgroup(segment) {
+				level = LevelQoS
+				continue
+			}
+			return LevelUnknown, "", ""
+		case LevelPod:
+			c, ok := parseContainerCgroup("/"+strings.Join(segments[:i+1], "/"), driver)
+			if !ok {
+				return LevelUnknown, "", ""
+			}
+			level, containerID = LevelContainer, c.ContainerID
+		case LevelContainer:
+			// Cgroups nested in the container, e.g. created by the container itself, belong to the container.
+			return level, podUID, containerID
+		}
+	}
+	return level, podUID, containerID
+}
+
+// isQoSCgroup reports whether the segment of a cgroup path is the cgroup of the burstable or the best effort QoS class.
+func isQoSCgroup(segment string) bool {
+	name := strings.TrimSuffix(segment, ".slice")
+	for _, qos := range []string{"burstable", "besteffort"} {
+		if name == qos || strings.HasSuffix(name, "-"+qos) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyK8sCgroup(t *testing.T) {
+	const podUID = "1ff39434-b35f-aeef-6415-9d11e3f96024"
+
+	tests := []struct {
+		name            string
+		path            string
+		wantLevel       Level
+		wantPodUID      string
+		wantContainerID string
+	}{
+		{
+			name:      "systemd root",
+			path:      "/sys/fs/cgroup/kubepods.slice",
+			wantLevel: LevelRoot,
+		},
+		{
+			name:      "systemd qos",
+			path:      "/kubepods.slice/kubepods-burstable.slice",
+			wantLevel: LevelQoS,
+		},
+		{
+			name:       "systemd pod",
+			path:       "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice",
+			wantLevel:  LevelPod,
+			wantPodUID: podUID,
+		},
+		{
+			name:       "systemd guaranteed pod",
+			path:       "/kubepods.slice/kubepods-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/",
+			wantLevel:  LevelPod,
+			wantPodUID: podUID,
+		},
+		{
+			name:            "systemd container",
+			path:            "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope",
+			wantLevel:       LevelContainer,
+			wantPodUID:      podUID,
+			wantContainerID: containerID,
+		},
+		{
+			name:            "systemd container nested in kind",
+			path:            "/system.slice/docker-" + containerID + ".scope/kubelet.slice/kubelet-kubepods.slice/kubelet-kubepods-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice/cri-containerd-" + containerID + ".scope",
+			wantLevel:       LevelContainer,
+			wantPodUID:      podUID,
+			wantContainerID: containerID,
+		},
+		{
+			name:      "cgroupfs root",
+			path:      "/kubepods",
+			wantLevel: LevelRoot,
+		},
+		{
+			name:      "cgroupfs qos",
+			path:      "/kubepods/besteffort",
+			wantLevel: LevelQoS,
+		},
+		{
+			name:       "cgroupfs guaranteed pod",
+			path:       "/kubepods/pod" + podUID,
+			wantLevel:  LevelPod,
+			wantPodUID: podUID,
+		},
+		{
+			name:            "cgroupfs container",
+			path:            "/kubepods/burstable/pod" + podUID + "/" + containerID,
+			wantLevel:       LevelContainer,
+			wantPodUID:      podUID,
+			wantContainerID: containerID,
+		},
+		{
+			name:            "cgroupfs nested in container",
+			path:            "/kubepods/burstable/pod" + podUID + "/" + containerID + "/init",
+			wantLevel:       LevelContainer,
+			wantPodUID:      podUID,
+			wantContainerID: containerID,
+		},
+		{
+			name: "not a container under the pod",
+			path: "/kubepods/burstable/pod" + podUID + "/foo",
+		},
+		{
+			name: "unexpected segment under the root",
+			path: "/kubepods.slice/foo.slice",
+		},
+		{
+			name: "systemd service",
+			path: "/system.slice/containerd.service",
+		},
+		{
+			name: "root",
+			path: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, podUID, containerID := ClassifyK8sCgroup(tt.path)
+			require.Equal(t, tt.wantLevel, level, level.String())
+			require.Equal(t, tt.wantPodUID, podUID)
+			require.Equal(t, tt.wantContainerID, containerID)
+		})
+	}
+}
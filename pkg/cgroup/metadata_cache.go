@@ -0,0 +1,194 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// MetadataCache maps cgroup IDs to the containers they belong to.
+// It indexes the cgroup hierarchy once, and then keeps the index up to date
+// by watching the hierarchy for created and removed cgroups with inotify.
+type MetadataCache struct {
+	logger  log.Logger
+	rootDir string
+	watcher *fsnotify.Watcher
+	// Resolves the cgroup ID of a path, replaced in tests.
+	id func(path string) (uint64, error)
+	// Reports whether a cgroup is pruned from the index, see WalkOption.
+	prune func(path string) bool
+
+	mtx    *sync.RWMutex
+	byID   map[uint64]ContainerCgroup
+	byPath map[string]uint64
+}
+
+// NewMetadataCache returns a MetadataCache for the cgroup hierarchy mounted at rootDir, e.g. /sys/fs/cgroup.
+// The cache is empty until Run is called.
+func NewMetadataCache(logger log.Logger, rootDir string, opts ...WalkOption) (*MetadataCache, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cgroup watcher: %w", err)
+	}
+
+	return &MetadataCache{
+		logger:  logger,
+		rootDir: rootDir,
+		watcher: watcher,
+		id:      ID,
+		prune:   defaultFS.pruner(opts),
+
+		mtx:    &sync.RWMutex{},
+		byID:   map[uint64]ContainerCgroup{},
+		byPath: map[string]uint64{},
+	}, nil
+}
+
+// Lookup returns the container the cgroup with the given ID belongs to.
+func (c *MetadataCache) Lookup(cgroupID uint64) (ContainerCgroup, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	cg, ok := c.byID[cgroupID]
+	return cg, ok
+}
+
+// Run builds the index and keeps it up to date until the context is canceled.
+func (c *MetadataCache) Run(ctx context.Context) error {
+	defer c.watcher.Close()
+
+	if err := c.rebuild(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return nil
+			}
+			c.handle(event)
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				level.Debug(c.logger).Log("msg", "error encountered while watching cgroups", "err", err)
+				continue
+			}
+			// Events have been dropped, e.g. during a burst of container churn, so the index can't be trusted anymore.
+			level.Debug(c.logger).Log("msg", "cgroup watcher queue overflowed, rebuilding the index")
+			if err := c.rebuild(); err != nil {
+				level.Warn(c.logger).Log("msg", "failed to rebuild the cgroup index", "err", err)
+			}
+		}
+	}
+}
+
+func (c *MetadataCache) handle(event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		byID := map[uint64]ContainerCgroup{}
+		byPath := map[string]uint64{}
+		// The children of the new cgroup could have been created before the watch is added, so walk it.
+		if err := c.index(event.Name, byID, byPath); err != nil {
+			level.Debug(c.logger).Log("msg", "failed to index created cgroup", "path", event.Name, "err", err)
+		}
+		c.mtx.Lock()
+		for path, id := range byPath {
+			c.byPath[path] = id
+			c.byID[id] = byID[id]
+		}
+		c.mtx.Unlock()
+	case event.Has(fsnotify.Remove):
+		// The watch of a removed directory is removed by the kernel.
+		c.mtx.Lock()
+		for path, id := range c.byPath {
+			if path == event.Name || strings.HasPrefix(path, event.Name+"/") {
+				delete(c.byPath, path)
+				delete(c.byID, id)
+			}
+		}
+		c.mtx.Unlock()
+	}
+}
+
+// rebuild indexes the whole hierarchy without holding the lock, and then swaps the index.
+func (c *MetadataCache) rebuild() error {
+	byID := map[uint64]ContainerCgroup{}
+	byPath := map[string]uint64{}
+	if err := c.index(c.rootDir, byID, byPath); err != nil {
+		return fmt.Errorf("failed to index cgroups: %w", err)
+	}
+
+	c.mtx.Lock()
+	c.byID = byID
+	c.byPath = byPath
+	c.mtx.Unlock()
+	return nil
+}
+
+// index watches all the cgroups under dir and adds the ones that belong to a container to the given maps.
+func (c *MetadataCache) index(dir string, byID map[uint64]ContainerCgroup, byPath map[string]uint64) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// The cgroup has been removed while walking.
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if c.prune(path) {
+			return fs.SkipDir
+		}
+
+		// Watch before reading, so cgroups created in between are not missed.
+		if err := c.watcher.Add(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+
+		cg, ok := ParseContainerCgroup(path)
+		if !ok {
+			return nil
+		}
+		id, err := c.id(path)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "failed to get cgroup ID", "path", path, "err", err)
+			return nil
+		}
+		cg.CgroupID = id
+		byID[id] = cg
+		byPath[path] = id
+		return nil
+	})
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataCache(t *testing.T) {
+	rootDir := t.TempDir()
+	existing := filepath.Join(rootDir, "docker", containerID)
+	require.NoError(t, os.MkdirAll(existing, 0o755))
+
+	c, err := NewMetadataCache(log.NewNopLogger(), rootDir)
+	require.NoError(t, err)
+
+	// Real cgroup IDs can't be resolved outside of a cgroup file system.
+	var (
+		mtx sync.Mutex
+		ids = map[string]uint64{}
+	)
+	c.id = func(path string) (uint64, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if _, ok := ids[path]; !ok {
+			ids[path] = uint64(len(ids) + 1)
+		}
+		return ids[path], nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	require.Eventually(t, func() bool {
+		cg, ok := c.Lookup(1)
+		return ok && cg.Path == existing && cg.Runtime == "docker"
+	}, time.Second, 10*time.Millisecond)
+
+	// Cgroups created with their parents at once, like a new pod.
+	otherID := strings.Repeat("b", 64)
+	created := filepath.Join(rootDir, "kubepods.slice", "kubepods-pod1ff39434_b35f_aeef_6415_9d11e3f96024.slice", "cri-containerd-"+otherID+".scope")
+	require.NoError(t, os.MkdirAll(created, 0o755))
+	require.Eventually(t, func() bool {
+		cg, ok := c.Lookup(2)
+		return ok && cg.ContainerID == otherID && cg.PodUID == "1ff39434-b35f-aeef-6415-9d11e3f96024"
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(rootDir, "docker")))
+	require.Eventually(t, func() bool {
+		_, ok := c.Lookup(1)
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	_, ok := c.Lookup(2)
+	require.True(t, ok)
+}
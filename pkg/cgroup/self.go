@@ -0,0 +1,71 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"os"
+	"sync"
+)
+
+// ProcessCgroup is the cgroup a process belongs to.
+type ProcessCgroup struct {
+	// V1Path and V2Path are the cgroup1 and cgroup2 paths of the process, as returned by Paths.
+	V1Path string
+	V2Path string
+	// ID is the cgroup2 ID of the cgroup. It is 0 if it could not be resolved, e.g. on cgroup1 only hosts.
+	ID uint64
+}
+
+var (
+	selfMtx sync.Mutex
+	self    *ProcessCgroup
+)
+
+// SelfCgroup returns the cgroup of the agent itself, e.g. to exclude it from profiling.
+// The cgroup of the agent is fixed for its lifetime, so it is resolved once and cached.
+// Errors are not cached, so a failed resolution is retried on the next call.
+func SelfCgroup() (ProcessCgroup, error) {
+	selfMtx.Lock()
+	defer selfMtx.Unlock()
+
+	if self != nil {
+		return *self, nil
+	}
+	cg, err := defaultFS.processCgroup(os.Getpid(), ID)
+	if err != nil {
+		return ProcessCgroup{}, err
+	}
+	self = &cg
+	return cg, nil
+}
+
+// processCgroup resolves the cgroup of the given process, using id to resolve the cgroup2 ID.
+func (f *FS) processCgroup(pid int, id func(pathWithMountpoint string) (uint64, error)) (ProcessCgroup, error) {
+	v1, v2, err := f.Paths(pid)
+	if err != nil {
+		return ProcessCgroup{}, err
+	}
+
+	cg := ProcessCgroup{V1Path: v1, V2Path: v2}
+	if v2 == "" {
+		return cg, nil
+	}
+	if pathWithMountpoint, err := f.PathV2AddMountpoint(v2); err == nil {
+		if cgroupID, err := id(pathWithMountpoint); err == nil {
+			cg.ID = cgroupID
+		}
+	}
+	return cg, nil
+}
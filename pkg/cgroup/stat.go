@@ -0,0 +1,650 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/procfs"
+)
+
+// ErrControllerNotEnabled is returned when the requested cgroup controller is not mounted or enabled.
+var ErrControllerNotEnabled = errors.New("cgroup controller is not enabled")
+
+// V1Mountpoint returns the mountpoint of the cgroup1 hierarchy the given controller is bound to.
+func V1Mountpoint(controller string) (string, error) {
+	return defaultFS.V1Mountpoint(controller)
+}
+
+// V1Mountpoint is like the package level V1Mountpoint, but reads from the file system of f.
+func (f *FS) V1Mountpoint(controller string) (string, error) {
+	file, err := f.fsys.Open("proc/self/mountinfo")
+	if err != nil {
+		return "", fmt.Errorf("failed to open mountinfo: %w", err)
+	}
+	defer file.Close()
+
+	mounts, err := parseMountInfo(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+	return v1Mountpoint(mounts, controller)
+}
+
+// parseMountInfo parses the mountpoint, the file system type and the super options
+// of the entries of /proc/[pid]/mountinfo.
+// See https://man7.org/linux/man-pages/man5/proc.5.html.
+func parseMountInfo(r io.Reader) ([]*procfs.MountInfo, error) {
+	var mounts []*procfs.MountInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		// e.g. 35 25 0:30 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid shared:13 - cgroup cgroup rw,cpu,cpuacct
+		before, after, ok := strings.Cut(text, " - ")
+		if !ok {
+			return nil, fmt.Errorf("malformed mountinfo line %q", text)
+		}
+		fields := strings.Fields(before)
+		superFields := strings.Fields(after)
+		if len(fields) < 6 || len(superFields) < 3 {
+			return nil, fmt.Errorf("malformed mountinfo line %q", text)
+		}
+		superOptions := map[string]string{}
+		for _, opt := range strings.Split(superFields[2], ",") {
+			k, v, _ := strings.Cut(opt, "=")
+			superOptions[k] = v
+		}
+		mounts = append(mounts, &procfs.MountInfo{
+			MountPoint:   fields[4],
+			FSType:       superFields[0],
+			Source:       superFields[1],
+			SuperOptions: superOptions,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+func v1Mountpoint(mounts []*procfs.MountInfo, controller string) (string, error) {
+	for _, m := range mounts {
+		if m.FSType != "cgroup" {
+			continue
+		}
+		// Controllers bound to a hierarchy are listed as super options, e.g. "rw,cpu,cpuacct".
+		if _, ok := m.SuperOptions[controller]; ok {
+			return m.MountPoint, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrControllerNotEnabled, controller)
+}
+
+// ReadCPUAcctPerCPU reads the per-CPU usage in nanoseconds of a cgroup1 cgroup
+// from cpuacct.usage_percpu. The cgroup path is relative to the hierarchy mountpoint.
+func ReadCPUAcctPerCPU(cgroupPath string) ([]uint64, error) {
+	return defaultFS.ReadCPUAcctPerCPU(cgroupPath)
+}
+
+// ReadCPUAcctPerCPU is like the package level ReadCPUAcctPerCPU, but reads from the file system of f.
+func (f *FS) ReadCPUAcctPerCPU(cgroupPath string) ([]uint64, error) {
+	path, err := f.PathV1AddMountpoint("cpuacct", cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.fsys.Open(rel(filepath.Join(path, "cpuacct.usage_percpu")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cpuacct.usage_percpu: %w", err)
+	}
+	defer file.Close()
+
+	return parseCPUAcctPerCPU(file)
+}
+
+func parseCPUAcctPerCPU(r io.Reader) ([]uint64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cpuacct.usage_percpu: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	usage := make([]uint64, 0, len(fields))
+	for _, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cpuacct.usage_percpu value %q: %w", field, err)
+		}
+		usage = append(usage, v)
+	}
+	return usage, nil
+}
+
+// DeviceID identifies a block device by its major and minor numbers.
+type DeviceID struct {
+	Major uint32
+	Minor uint32
+}
+
+// IOStat is the I/O accounting of a cgroup for a block device.
+type IOStat struct {
+	// RBytes and WBytes are the bytes read and written.
+	RBytes uint64
+	WBytes uint64
+	// RIOs and WIOs are the number of read and write I/Os.
+	RIOs uint64
+	WIOs uint64
+}
+
+// ReadIOStat reads the I/O accounting of a cgroup2 cgroup from io.stat, by device.
+// The cgroup path includes the mountpoint.
+// It returns ErrControllerNotEnabled if the io controller isn't enabled for the cgroup.
+func ReadIOStat(cgroupPath string) (map[DeviceID]IOStat, error) {
+	return defaultFS.ReadIOStat(cgroupPath)
+}
+
+// ReadIOStat is like the package level ReadIOStat, but reads from the file system of f.
+func (f *FS) ReadIOStat(cgroupPath string) (map[DeviceID]IOStat, error) {
+	if err := f.checkController(cgroupPath, "io"); err != nil {
+		return nil, err
+	}
+
+	file, err := f.fsys.Open(rel(filepath.Join(cgroupPath, "io.stat")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open io.stat: %w", err)
+	}
+	defer file.Close()
+
+	return parseIOStat(file)
+}
+
+// parseIOStat parses a cgroup2 io.stat file, with a line per device,
+// e.g. "8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252 dbytes=50331648 dios=3021".
+// The keys other than the bytes and the number of I/Os read and written, e.g. the discards, are ignored.
+func parseIOStat(r io.Reader) (map[DeviceID]IOStat, error) {
+	stats := map[DeviceID]IOStat{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		majorText, minorText, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid device %q", fields[0])
+		}
+		major, err := strconv.ParseUint(majorText, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse device %q: %w", fields[0], err)
+		}
+		minor, err := strconv.ParseUint(minorText, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse device %q: %w", fields[0], err)
+		}
+
+		var stat IOStat
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid io.stat field %q", field)
+			}
+			var dst *uint64
+			switch key {
+			case "rbytes":
+				dst = &stat.RBytes
+			case "wbytes":
+				dst = &stat.WBytes
+			case "rios":
+				dst = &stat.RIOs
+			case "wios":
+				dst = &stat.WIOs
+			default:
+				continue
+			}
+			*dst, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse io.stat %s value %q: %w", key, value, err)
+			}
+		}
+		stats[DeviceID{Major: uint32(major), Minor: uint32(minor)}] = stat
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read io.stat: %w", err)
+	}
+	return stats, nil
+}
+
+// memoryUnlimitedV1 is the lowest value of memory.limit_in_bytes reported for cgroup1 cgroups without a limit.
+// The kernel reports LONG_MAX rounded down to the page size, e.g. 0x7FFFFFFFFFFFF000 with 4K pages,
+// so this is LONG_MAX rounded down to the largest supported page size, 64K.
+const memoryUnlimitedV1 = 0x7FFFFFFFFFFF0000
+
+// ReadMemoryLimitV1 reads the memory limit in bytes of a cgroup1 cgroup from memory.limit_in_bytes.
+// The cgroup path is relative to the hierarchy mountpoint.
+// It returns unlimited=true if the cgroup doesn't have a limit, instead of the value reported by the kernel.
+func ReadMemoryLimitV1(cgroupPath string) (limit uint64, unlimited bool, err error) { //nolint:nonamedreturns
+	return defaultFS.ReadMemoryLimitV1(cgroupPath)
+}
+
+// ReadMemoryLimitV1 is like the package level ReadMemoryLimitV1, but reads from the file system of f.
+func (f *FS) ReadMemoryLimitV1(cgroupPath string) (limit uint64, unlimited bool, err error) { //nolint:nonamedreturns
+	path, err := f.PathV1AddMountpoint("memory", cgroupPath)
+	if err != nil {
+		return 0, false, err
+	}
+
+	limit, err = f.readUint(filepath.Join(path, "memory.limit_in_bytes"))
+	if err != nil {
+		return 0, false, err
+	}
+	if limit >= memoryUnlimitedV1 {
+		return 0, true, nil
+	}
+	return limit, false, nil
+}
+
+// PIDsInCgroup returns the PIDs of the processes in the cgroup at the given path, including the mountpoint.
+// It reads cgroup.procs, which exists for both cgroup1 and cgroup2, falling back to tasks for cgroup1.
+// The result is a snapshot: processes could have exited or moved to another cgroup since.
+func PIDsInCgroup(cgroupPath string) ([]int, error) {
+	return defaultFS.PIDsInCgroup(cgroupPath)
+}
+
+// PIDsInCgroup is like the package level PIDsInCgroup, but reads from the file system of f.
+func (f *FS) PIDsInCgroup(cgroupPath string) ([]int, error) {
+	file, err := f.fsys.Open(rel(filepath.Join(cgroupPath, "cgroup.procs")))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = f.fsys.Open(rel(filepath.Join(cgroupPath, "tasks")))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the processes of cgroup %s: %w", cgroupPath, err)
+	}
+	defer file.Close()
+
+	return parsePIDs(file)
+}
+
+func parsePIDs(r io.Reader) ([]int, error) {
+	var pids []int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		pid, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PID %q: %w", text, err)
+		}
+		pids = append(pids, pid)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read PIDs: %w", err)
+	}
+	return pids, nil
+}
+
+// ReadPIDsStat reads the number of processes and threads in a cgroup2 cgroup from pids.current,
+// and its limit from pids.max, e.g. to detect fork bombs.
+// The cgroup path includes the mountpoint.
+// It returns unlimited=true if the cgroup doesn't have a limit, and ErrControllerNotEnabled
+// if the pids controller isn't enabled for the cgroup.
+func ReadPIDsStat(cgroupPath string) (current, limit uint64, unlimited bool, err error) { //nolint:nonamedreturns
+	return defaultFS.ReadPIDsStat(cgroupPath)
+}
+
+// ReadPIDsStat is like the package level ReadPIDsStat, but reads from the file system of f.
+func (f *FS) ReadPIDsStat(cgroupPath string) (current, limit uint64, unlimited bool, err error) { //nolint:nonamedreturns
+	if err := f.checkController(cgroupPath, "pids"); err != nil {
+		return 0, 0, false, err
+	}
+
+	current, err = f.readUint(filepath.Join(cgroupPath, "pids.current"))
+	if err != nil {
+		return 0, 0, false, err
+	}
+	limit, unlimited, err = f.readMax(filepath.Join(cgroupPath, "pids.max"))
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return current, limit, unlimited, nil
+}
+
+// HugeTLBStat is the usage and the limit of the huge pages of a size of a cgroup.
+type HugeTLBStat struct {
+	// Current is the usage of the huge pages in bytes.
+	Current uint64
+	// Max is the limit of the huge pages in bytes. It is 0 if Unlimited is true.
+	Max       uint64
+	Unlimited bool
+}
+
+// ReadHugeTLB reads the usage and the limit of the huge pages of a cgroup2 cgroup
+// from hugetlb.<size>.current and hugetlb.<size>.max, by page size, e.g. 2MB and 1GB.
+// The cgroup path includes the mountpoint.
+// It returns ErrControllerNotEnabled if the hugetlb controller isn't enabled for the cgroup.
+func ReadHugeTLB(cgroupPath string) (map[string]HugeTLBStat, error) {
+	return defaultFS.ReadHugeTLB(cgroupPath)
+}
+
+// ReadHugeTLB is like the package level ReadHugeTLB, but reads from the file system of f.
+func (f *FS) ReadHugeTLB(cgroupPath string) (map[string]HugeTLBStat, error) {
+	if err := f.checkController(cgroupPath, "hugetlb"); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(f.fsys, rel(cgroupPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup %s: %w", cgroupPath, err)
+	}
+	stats := map[string]HugeTLBStat{}
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, "hugetlb.") || !strings.HasSuffix(name, ".current") {
+			continue
+		}
+		size := strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), ".current")
+		// e.g. hugetlb.2MB.rsvd.current, the reservations are accounted separately.
+		if strings.Contains(size, ".") {
+			continue
+		}
+
+		var stat HugeTLBStat
+		stat.Current, err = f.readUint(filepath.Join(cgroupPath, name))
+		if err != nil {
+			return nil, err
+		}
+		stat.Max, stat.Unlimited, err = f.readMax(filepath.Join(cgroupPath, "hugetlb."+size+".max"))
+		if err != nil {
+			return nil, err
+		}
+		stats[size] = stat
+	}
+	return stats, nil
+}
+
+// ReadMisc reads the usage of the resources of the misc controller of a cgroup2 cgroup from misc.current,
+// by resource name, e.g. sev and sev_es.
+// The cgroup path includes the mountpoint.
+// It returns ErrControllerNotEnabled if the misc controller isn't enabled for the cgroup.
+func ReadMisc(cgroupPath string) (map[string]uint64, error) {
+	return defaultFS.ReadMisc(cgroupPath)
+}
+
+// ReadMisc is like the package level ReadMisc, but reads from the file system of f.
+func (f *FS) ReadMisc(cgroupPath string) (map[string]uint64, error) {
+	if err := f.checkController(cgroupPath, "misc"); err != nil {
+		return nil, err
+	}
+
+	file, err := f.fsys.Open(rel(filepath.Join(cgroupPath, "misc.current")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open misc.current: %w", err)
+	}
+	defer file.Close()
+
+	return parseFlatKeyed(file)
+}
+
+// parseFlatKeyed parses a flat keyed cgroup2 file, e.g. misc.current, with a "<key> <value>" line per key.
+func parseFlatKeyed(r io.Reader) (map[string]uint64, error) {
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", text)
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s value %q: %w", key, value, err)
+		}
+		values[key] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	return values, nil
+}
+
+// RootControllers returns the cgroup2 controllers available on the host, as listed in the cgroup.controllers file
+// of the root cgroup, e.g. to log which resource statistics can be collected.
+// The controllers that are bound to cgroup1 hierarchies, e.g. in the hybrid mode, are not available.
+// It returns an error wrapping fs.ErrNotExist if cgroup2 is not mounted.
+func RootControllers() ([]string, error) {
+	return defaultFS.RootControllers()
+}
+
+// RootControllers is like the package level RootControllers, but reads from the file system of f.
+func (f *FS) RootControllers() ([]string, error) {
+	for _, mountpoint := range []string{"/sys/fs/cgroup/unified", "/sys/fs/cgroup"} {
+		controllers, err := f.readControllers(mountpoint)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return controllers, err
+	}
+	return nil, fmt.Errorf("cgroup2 is not mounted: %w", fs.ErrNotExist)
+}
+
+// checkController returns ErrControllerNotEnabled if the controller isn't listed in the cgroup.controllers
+// file of the cgroup2 cgroup at the given path, including the mountpoint.
+func (f *FS) checkController(cgroupPath, controller string) error {
+	controllers, err := f.readControllers(cgroupPath)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(controllers, controller) {
+		return fmt.Errorf("%w: %s", ErrControllerNotEnabled, controller)
+	}
+	return nil
+}
+
+// readControllers returns the controllers listed in the cgroup.controllers file of the cgroup2 cgroup at the given path,
+// including the mountpoint.
+func (f *FS) readControllers(cgroupPath string) ([]string, error) {
+	data, err := fs.ReadFile(f.fsys, rel(filepath.Join(cgroupPath, "cgroup.controllers")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cgroup.controllers: %w", err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// readMax reads a cgroup2 limit file, e.g. pids.max, which contains either a single unsigned integer or "max".
+func (f *FS) readMax(path string) (limit uint64, unlimited bool, err error) { //nolint:nonamedreturns
+	data, err := fs.ReadFile(f.fsys, rel(path))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, true, nil
+	}
+	limit, err = strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to parse %s value %q: %w", filepath.Base(path), value, err)
+	}
+	return limit, false, nil
+}
+
+// UnifiedStats are the resource usage statistics of a cgroup, regardless of the cgroup version.
+type UnifiedStats struct {
+	// CPUUsage is the total CPU time consumed by the processes of the cgroup.
+	CPUUsage time.Duration
+	// MemoryUsage is the current memory usage of the cgroup in bytes, including the page cache.
+	MemoryUsage uint64
+}
+
+// Stats returns the CPU and memory usage of a cgroup, hiding the differences between cgroup1 and cgroup2.
+// The cgroup path is relative to the hierarchy mountpoint, e.g. as read from /proc/[pid]/cgroup.
+// The cgroup1 hierarchies are used if the cpuacct controller is mounted (including hybrid setups),
+// otherwise the cgroup2 unified hierarchy is used.
+func Stats(cgroupPath string) (UnifiedStats, error) {
+	return defaultFS.Stats(cgroupPath)
+}
+
+// Stats is like the package level Stats, but reads from the file system of f.
+func (f *FS) Stats(cgroupPath string) (UnifiedStats, error) {
+	file, err := f.fsys.Open("proc/self/mountinfo")
+	if err != nil {
+		return UnifiedStats{}, fmt.Errorf("failed to open mountinfo: %w", err)
+	}
+	mounts, err := parseMountInfo(file)
+	file.Close()
+	if err != nil {
+		return UnifiedStats{}, fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+
+	if cpuacct, err := v1Mountpoint(mounts, "cpuacct"); err == nil {
+		return f.v1Stats(mounts, cpuacct, cgroupPath)
+	}
+	for _, m := range mounts {
+		if m.FSType == "cgroup2" {
+			return f.v2Stats(filepath.Join(m.MountPoint, cgroupPath))
+		}
+	}
+	return UnifiedStats{}, fmt.Errorf("%w: neither cpuacct nor cgroup2 is mounted", ErrControllerNotEnabled)
+}
+
+func (f *FS) v1Stats(mounts []*procfs.MountInfo, cpuacct, cgroupPath string) (UnifiedStats, error) {
+	usage, err := f.readUint(filepath.Join(cpuacct, cgroupPath, "cpuacct.usage"))
+	if err != nil {
+		return UnifiedStats{}, err
+	}
+	memory, err := v1Mountpoint(mounts, "memory")
+	if err != nil {
+		return UnifiedStats{}, err
+	}
+	memoryUsage, err := f.readUint(filepath.Join(memory, cgroupPath, "memory.usage_in_bytes"))
+	if err != nil {
+		return UnifiedStats{}, err
+	}
+	return UnifiedStats{
+		CPUUsage:    time.Duration(usage),
+		MemoryUsage: memoryUsage,
+	}, nil
+}
+
+func (f *FS) v2Stats(cgroupPath string) (UnifiedStats, error) {
+	file, err := f.fsys.Open(rel(filepath.Join(cgroupPath, "cpu.stat")))
+	if err != nil {
+		return UnifiedStats{}, fmt.Errorf("failed to open cpu.stat: %w", err)
+	}
+	usage, err := parseCPUStatUsage(file)
+	file.Close()
+	if err != nil {
+		return UnifiedStats{}, err
+	}
+	memoryUsage, err := f.readUint(filepath.Join(cgroupPath, "memory.current"))
+	if err != nil {
+		return UnifiedStats{}, err
+	}
+	return UnifiedStats{
+		CPUUsage:    time.Duration(usage) * time.Microsecond,
+		MemoryUsage: memoryUsage,
+	}, nil
+}
+
+// parseCPUStatUsage returns the usage_usec field of a cgroup2 cpu.stat file.
+func parseCPUStatUsage(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || key != "usage_usec" {
+			continue
+		}
+		usage, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse cpu.stat usage_usec value %q: %w", value, err)
+		}
+		return usage, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read cpu.stat: %w", err)
+	}
+	return 0, errors.New("cpu.stat has no usage_usec field")
+}
+
+// readUint reads a file containing a single unsigned integer, e.g. memory.current.
+func (f *FS) readUint(path string) (uint64, error) {
+	data, err := fs.ReadFile(f.fsys, rel(path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s value %q: %w", filepath.Base(path), data, err)
+	}
+	return v, nil
+}
+
+// Type is the type of a cgroup2 cgroup, as read from cgroup.type.
+// See https://docs.kernel.org/admin-guide/cgroup-v2.html#threads.
+type Type string
+
+const (
+	// TypeDomain is a normal cgroup, where all the controllers apply.
+	TypeDomain Type = "domain"
+	// TypeDomainThreaded is the root of a threaded subtree.
+	TypeDomainThreaded Type = "domain threaded"
+	// TypeDomainInvalid is a cgroup in an invalid state, which can't be populated or have controllers enabled.
+	TypeDomainInvalid Type = "domain invalid"
+	// TypeThreaded is a member of a threaded subtree, where only the threaded controllers (e.g. cpu) apply.
+	// The stats of the domain controllers, e.g. memory, are only available in the root of the subtree.
+	TypeThreaded Type = "threaded"
+)
+
+// CgroupType returns the type of a cgroup2 cgroup at the given path, including the mountpoint.
+// It returns TypeDomain if the cgroup doesn't have a cgroup.type file, e.g. the root cgroup or on kernels older than 4.14.
+func CgroupType(cgroupPath string) (Type, error) {
+	return defaultFS.CgroupType(cgroupPath)
+}
+
+// CgroupType is like the package level CgroupType, but reads from the file system of f.
+func (f *FS) CgroupType(cgroupPath string) (Type, error) {
+	if _, err := fs.Stat(f.fsys, rel(cgroupPath)); err != nil {
+		return "", fmt.Errorf("cannot access cgroup %q: %w", cgroupPath, err)
+	}
+	data, err := fs.ReadFile(f.fsys, rel(filepath.Join(cgroupPath, "cgroup.type")))
+	if errors.Is(err, fs.ErrNotExist) {
+		return TypeDomain, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cgroup.type: %w", err)
+	}
+
+	switch t := Type(strings.TrimSpace(string(data))); t {
+	case TypeDomain, TypeDomainThreaded, TypeDomainInvalid, TypeThreaded:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown cgroup type %q", t)
+	}
+}
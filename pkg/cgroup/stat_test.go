@@ -0,0 +1,359 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/prometheus/procfs"
+	"github.com/stretchr/testify/require"
+)
+
+var v1Mounts = []*procfs.MountInfo{
+	{MountPoint: "/sys/fs/cgroup", FSType: "tmpfs", SuperOptions: map[string]string{"ro": ""}},
+	{MountPoint: "/sys/fs/cgroup/unified", FSType: "cgroup2", SuperOptions: map[string]string{"rw": ""}},
+	{MountPoint: "/sys/fs/cgroup/systemd", FSType: "cgroup", SuperOptions: map[string]string{"rw": "", "name": "systemd"}},
+	{MountPoint: "/sys/fs/cgroup/cpu,cpuacct", FSType: "cgroup", SuperOptions: map[string]string{"rw": "", "cpu": "", "cpuacct": ""}},
+	{MountPoint: "/sys/fs/cgroup/memory", FSType: "cgroup", SuperOptions: map[string]string{"rw": "", "memory": ""}},
+}
+
+func TestV1Mountpoint(t *testing.T) {
+	got, err := v1Mountpoint(v1Mounts, "cpuacct")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/cpu,cpuacct", got)
+
+	got, err = v1Mountpoint(v1Mounts, "memory")
+	require.NoError(t, err)
+	require.Equal(t, "/sys/fs/cgroup/memory", got)
+
+	_, err = v1Mountpoint(v1Mounts, "cpuset")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+}
+
+func TestParseCPUAcctPerCPU(t *testing.T) {
+	got, err := parseCPUAcctPerCPU(strings.NewReader("5315264716 4871529493 0 18446744073709551615 \n"))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5315264716, 4871529493, 0, 18446744073709551615}, got)
+
+	_, err = parseCPUAcctPerCPU(strings.NewReader("1 two 3\n"))
+	require.Error(t, err)
+}
+
+const mountInfoV1 = `25 30 0:23 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+33 25 0:28 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:9 - tmpfs tmpfs ro,mode=755
+34 33 0:29 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:10 - cgroup2 cgroup2 rw,nsdelegate
+35 33 0:30 / /sys/fs/cgroup/systemd rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,xattr,name=systemd
+38 33 0:33 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:14 - cgroup cgroup rw,cpu,cpuacct
+39 33 0:34 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:15 - cgroup cgroup rw,memory
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(mountInfoV1))
+	require.NoError(t, err)
+	require.Len(t, mounts, 6)
+	require.Equal(t, &procfs.MountInfo{
+		MountPoint:   "/sys/fs/cgroup/cpu,cpuacct",
+		FSType:       "cgroup",
+		Source:       "cgroup",
+		SuperOptions: map[string]string{"rw": "", "cpu": "", "cpuacct": ""},
+	}, mounts[4])
+
+	_, err = parseMountInfo(strings.NewReader("35 33 0:30 / /sys/fs/cgroup/systemd rw\n"))
+	require.Error(t, err)
+}
+
+func TestReadCPUAcctPerCPU(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte(mountInfoV1)},
+		"sys/fs/cgroup/cpu,cpuacct/docker/a/cpuacct.usage_percpu": &fstest.MapFile{Data: []byte("10 20\n")},
+	})
+
+	got, err := cgroupFS.ReadCPUAcctPerCPU("/docker/a")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{10, 20}, got)
+
+	_, err = cgroupFS.ReadCPUAcctPerCPU("/docker/b")
+	require.Error(t, err)
+
+	cgroupFS = NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte("34 33 0:29 / /sys/fs/cgroup rw shared:10 - cgroup2 cgroup2 rw\n")},
+	})
+	_, err = cgroupFS.ReadCPUAcctPerCPU("/docker/a")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+}
+
+func TestReadIOStat(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/db.slice/cgroup.controllers": &fstest.MapFile{Data: []byte("cpu io memory\n")},
+		"sys/fs/cgroup/db.slice/io.stat": &fstest.MapFile{Data: []byte(
+			"8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252 dbytes=50331648 dios=3021\n" +
+				"253:1 rbytes=4096 wbytes=0 rios=1 wios=0\n",
+		)},
+		"sys/fs/cgroup/idle.slice/cgroup.controllers":   &fstest.MapFile{Data: []byte("io\n")},
+		"sys/fs/cgroup/idle.slice/io.stat":              &fstest.MapFile{},
+		"sys/fs/cgroup/other.slice/cgroup.controllers":  &fstest.MapFile{Data: []byte("cpu memory\n")},
+		"sys/fs/cgroup/broken.slice/cgroup.controllers": &fstest.MapFile{Data: []byte("io\n")},
+		"sys/fs/cgroup/broken.slice/io.stat":            &fstest.MapFile{Data: []byte("8:0 rbytes=foo\n")},
+	})
+
+	got, err := cgroupFS.ReadIOStat("/sys/fs/cgroup/db.slice")
+	require.NoError(t, err)
+	require.Equal(t, map[DeviceID]IOStat{
+		{Major: 8, Minor: 0}:   {RBytes: 90430464, WBytes: 299008000, RIOs: 8950, WIOs: 1252},
+		{Major: 253, Minor: 1}: {RBytes: 4096, RIOs: 1},
+	}, got)
+
+	got, err = cgroupFS.ReadIOStat("/sys/fs/cgroup/idle.slice")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = cgroupFS.ReadIOStat("/sys/fs/cgroup/other.slice")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+	_, err = cgroupFS.ReadIOStat("/sys/fs/cgroup/broken.slice")
+	require.Error(t, err)
+}
+
+func TestPIDsInCgroup(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/system.slice/containerd.service/cgroup.procs": &fstest.MapFile{Data: []byte("1\n42\n")},
+		"sys/fs/cgroup/cpu,cpuacct/docker/a/tasks":                   &fstest.MapFile{Data: []byte("7\n")},
+		"sys/fs/cgroup/system.slice/empty.service/cgroup.procs":      &fstest.MapFile{},
+		"sys/fs/cgroup/system.slice/broken.service/cgroup.procs":     &fstest.MapFile{Data: []byte("1\nfoo\n")},
+	})
+
+	got, err := cgroupFS.PIDsInCgroup("/sys/fs/cgroup/system.slice/containerd.service")
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 42}, got)
+
+	got, err = cgroupFS.PIDsInCgroup("/sys/fs/cgroup/cpu,cpuacct/docker/a")
+	require.NoError(t, err)
+	require.Equal(t, []int{7}, got)
+
+	got, err = cgroupFS.PIDsInCgroup("/sys/fs/cgroup/system.slice/empty.service")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = cgroupFS.PIDsInCgroup("/sys/fs/cgroup/system.slice/broken.service")
+	require.Error(t, err)
+
+	_, err = cgroupFS.PIDsInCgroup("/sys/fs/cgroup/system.slice/missing.service")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestStats(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"proc/self/mountinfo":                                 &fstest.MapFile{Data: []byte(mountInfoV1)},
+		"sys/fs/cgroup/cpu,cpuacct/docker/a/cpuacct.usage":    &fstest.MapFile{Data: []byte("1500000000\n")},
+		"sys/fs/cgroup/memory/docker/a/memory.usage_in_bytes": &fstest.MapFile{Data: []byte("4096\n")},
+	})
+	got, err := cgroupFS.Stats("/docker/a")
+	require.NoError(t, err)
+	require.Equal(t, UnifiedStats{CPUUsage: 1500 * time.Millisecond, MemoryUsage: 4096}, got)
+
+	cgroupFS = NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte("34 33 0:29 / /sys/fs/cgroup rw shared:10 - cgroup2 cgroup2 rw\n")},
+		"sys/fs/cgroup/system.slice/docker-a.scope/cpu.stat": &fstest.MapFile{
+			Data: []byte("usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n"),
+		},
+		"sys/fs/cgroup/system.slice/docker-a.scope/memory.current": &fstest.MapFile{Data: []byte("4096\n")},
+	})
+	got, err = cgroupFS.Stats("/system.slice/docker-a.scope")
+	require.NoError(t, err)
+	require.Equal(t, UnifiedStats{CPUUsage: 1500 * time.Millisecond, MemoryUsage: 4096}, got)
+
+	_, err = cgroupFS.Stats("/system.slice/missing.scope")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	cgroupFS = NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte("25 30 0:23 / /sys rw - sysfs sysfs rw\n")},
+	})
+	_, err = cgroupFS.Stats("/")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+}
+
+func FuzzParseMountInfo(f *testing.F) {
+	for _, contents := range []string{mountInfoV1, "", " - ", "1 2 3 4 5 6 - a b c"} {
+		f.Add([]byte(contents))
+	}
+
+	f.Fuzz(func(t *testing.T, contents []byte) {
+		// Mustn't panic.
+		mounts, err := parseMountInfo(strings.NewReader(string(contents)))
+		if err != nil {
+			return
+		}
+		// Every line has the mount fields and the super block fields, separated by " - ".
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSuffix(line, "\r") // As bufio.ScanLines.
+			if line != "" && !strings.Contains(line, " - ") {
+				t.Fatalf("malformed line %q was accepted", line)
+			}
+		}
+		// Mustn't panic either.
+		_, _ = v1Mountpoint(mounts, "cpuacct")
+	})
+}
+
+func TestReadMemoryLimitV1(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"proc/self/mountinfo": &fstest.MapFile{Data: []byte(mountInfoV1)},
+		"sys/fs/cgroup/memory/docker/a/memory.limit_in_bytes": &fstest.MapFile{Data: []byte("536870912\n")},
+		"sys/fs/cgroup/memory/docker/b/memory.limit_in_bytes": &fstest.MapFile{Data: []byte("9223372036854771712\n")},
+		// With 64K pages.
+		"sys/fs/cgroup/memory/docker/c/memory.limit_in_bytes": &fstest.MapFile{Data: []byte("9223372036854710272\n")},
+	})
+
+	limit, unlimited, err := cgroupFS.ReadMemoryLimitV1("/docker/a")
+	require.NoError(t, err)
+	require.False(t, unlimited)
+	require.Equal(t, uint64(512<<20), limit)
+
+	for _, path := range []string{"/docker/b", "/docker/c"} {
+		limit, unlimited, err = cgroupFS.ReadMemoryLimitV1(path)
+		require.NoError(t, err)
+		require.True(t, unlimited)
+		require.Zero(t, limit)
+	}
+
+	_, _, err = cgroupFS.ReadMemoryLimitV1("/docker/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestCgroupType(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/system.slice/cgroup.type":                     &fstest.MapFile{Data: []byte("domain\n")},
+		"sys/fs/cgroup/app.slice/cgroup.type":                        &fstest.MapFile{Data: []byte("domain threaded\n")},
+		"sys/fs/cgroup/app.slice/workers/cgroup.type":                &fstest.MapFile{Data: []byte("threaded\n")},
+		"sys/fs/cgroup/app.slice/invalid/cgroup.type":                &fstest.MapFile{Data: []byte("domain invalid\n")},
+		"sys/fs/cgroup/old.slice/cgroup.procs":                       &fstest.MapFile{},
+		"sys/fs/cgroup/broken.slice/cgroup.type":                     &fstest.MapFile{Data: []byte("foo\n")},
+		"sys/fs/cgroup/system.slice/containerd.service/cgroup.procs": &fstest.MapFile{},
+	})
+
+	for path, want := range map[string]Type{
+		"/sys/fs/cgroup/system.slice":      TypeDomain,
+		"/sys/fs/cgroup/app.slice":         TypeDomainThreaded,
+		"/sys/fs/cgroup/app.slice/workers": TypeThreaded,
+		"/sys/fs/cgroup/app.slice/invalid": TypeDomainInvalid,
+		"/sys/fs/cgroup/old.slice":         TypeDomain,
+	} {
+		got, err := cgroupFS.CgroupType(path)
+		require.NoError(t, err, path)
+		require.Equal(t, want, got, path)
+	}
+
+	_, err := cgroupFS.CgroupType("/sys/fs/cgroup/broken.slice")
+	require.Error(t, err)
+	_, err = cgroupFS.CgroupType("/sys/fs/cgroup/missing.slice")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestReadPIDsStat(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/limited.slice/cgroup.controllers":   &fstest.MapFile{Data: []byte("cpu memory pids\n")},
+		"sys/fs/cgroup/limited.slice/pids.current":         &fstest.MapFile{Data: []byte("12\n")},
+		"sys/fs/cgroup/limited.slice/pids.max":             &fstest.MapFile{Data: []byte("4096\n")},
+		"sys/fs/cgroup/unlimited.slice/cgroup.controllers": &fstest.MapFile{Data: []byte("pids\n")},
+		"sys/fs/cgroup/unlimited.slice/pids.current":       &fstest.MapFile{Data: []byte("3\n")},
+		"sys/fs/cgroup/unlimited.slice/pids.max":           &fstest.MapFile{Data: []byte("max\n")},
+		"sys/fs/cgroup/disabled.slice/cgroup.controllers":  &fstest.MapFile{Data: []byte("cpu memory\n")},
+	})
+
+	current, limit, unlimited, err := cgroupFS.ReadPIDsStat("/sys/fs/cgroup/limited.slice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), current)
+	require.Equal(t, uint64(4096), limit)
+	require.False(t, unlimited)
+
+	current, limit, unlimited, err = cgroupFS.ReadPIDsStat("/sys/fs/cgroup/unlimited.slice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), current)
+	require.Equal(t, uint64(0), limit)
+	require.True(t, unlimited)
+
+	_, _, _, err = cgroupFS.ReadPIDsStat("/sys/fs/cgroup/disabled.slice")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+	_, _, _, err = cgroupFS.ReadPIDsStat("/sys/fs/cgroup/missing.slice")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestReadHugeTLB(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/db.slice/cgroup.controllers":       &fstest.MapFile{Data: []byte("cpu memory hugetlb\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.2MB.current":      &fstest.MapFile{Data: []byte("4194304\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.2MB.max":          &fstest.MapFile{Data: []byte("max\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.2MB.rsvd.current": &fstest.MapFile{Data: []byte("0\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.2MB.rsvd.max":     &fstest.MapFile{Data: []byte("max\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.1GB.current":      &fstest.MapFile{Data: []byte("0\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.1GB.max":          &fstest.MapFile{Data: []byte("2147483648\n")},
+		"sys/fs/cgroup/db.slice/hugetlb.1GB.events":       &fstest.MapFile{Data: []byte("max 0\n")},
+		"sys/fs/cgroup/other.slice/cgroup.controllers":    &fstest.MapFile{Data: []byte("cpu memory\n")},
+		"sys/fs/cgroup/broken.slice/cgroup.controllers":   &fstest.MapFile{Data: []byte("hugetlb\n")},
+		"sys/fs/cgroup/broken.slice/hugetlb.2MB.current":  &fstest.MapFile{Data: []byte("foo\n")},
+		"sys/fs/cgroup/broken.slice/hugetlb.2MB.max":      &fstest.MapFile{Data: []byte("max\n")},
+	})
+
+	got, err := cgroupFS.ReadHugeTLB("/sys/fs/cgroup/db.slice")
+	require.NoError(t, err)
+	require.Equal(t, map[string]HugeTLBStat{
+		"2MB": {Current: 4194304, Unlimited: true},
+		"1GB": {Current: 0, Max: 2147483648},
+	}, got)
+
+	_, err = cgroupFS.ReadHugeTLB("/sys/fs/cgroup/other.slice")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+	_, err = cgroupFS.ReadHugeTLB("/sys/fs/cgroup/broken.slice")
+	require.Error(t, err)
+}
+
+func TestReadMisc(t *testing.T) {
+	cgroupFS := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/vm.slice/cgroup.controllers":    &fstest.MapFile{Data: []byte("cpu misc\n")},
+		"sys/fs/cgroup/vm.slice/misc.current":          &fstest.MapFile{Data: []byte("sev 3\nsev_es 0\n")},
+		"sys/fs/cgroup/other.slice/cgroup.controllers": &fstest.MapFile{Data: []byte("cpu\n")},
+	})
+
+	got, err := cgroupFS.ReadMisc("/sys/fs/cgroup/vm.slice")
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint64{"sev": 3, "sev_es": 0}, got)
+
+	_, err = cgroupFS.ReadMisc("/sys/fs/cgroup/other.slice")
+	require.ErrorIs(t, err, ErrControllerNotEnabled)
+}
+
+func TestRootControllers(t *testing.T) {
+	got, err := NewFS(fstest.MapFS{
+		"sys/fs/cgroup/cgroup.controllers": &fstest.MapFile{Data: []byte("cpuset cpu io memory hugetlb pids rdma misc\n")},
+	}).RootControllers()
+	require.NoError(t, err)
+	require.Equal(t, []string{"cpuset", "cpu", "io", "memory", "hugetlb", "pids", "rdma", "misc"}, got)
+
+	// In the hybrid mode, the controllers bound to cgroup1 are not available in cgroup2.
+	got, err = NewFS(fstest.MapFS{
+		"sys/fs/cgroup/unified/cgroup.controllers": &fstest.MapFile{Data: []byte("\n")},
+		"sys/fs/cgroup/memory/cgroup.procs":        &fstest.MapFile{},
+	}).RootControllers()
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = NewFS(fstest.MapFS{
+		"sys/fs/cgroup/memory/cgroup.procs": &fstest.MapFile{},
+	}).RootControllers()
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cgroup
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkOption configures the walks of the cgroup hierarchy, e.g. of EachContainer.
+type WalkOption func(*walkOptions)
+
+type walkOptions struct {
+	skipSelf bool
+}
+
+// SkipSelf prunes the cgroup of the agent itself (see SelfCgroup), and the cgroups nested in it, from the walk,
+// so the agent doesn't profile itself. Diagnostic tools that want to see all the cgroups shouldn't use it.
+// The root cgroup is never pruned, e.g. when the agent runs in a cgroup namespace.
+// If the cgroup of the agent can't be resolved, nothing is pruned.
+func SkipSelf() WalkOption {
+	return func(o *walkOptions) {
+		o.skipSelf = true
+	}
+}
+
+// pruner returns a function that reports whether the cgroup at the given path, including the mountpoint,
+// should be pruned from a walk with the given options.
+func (f *FS) pruner(opts []WalkOption) func(pathWithMountpoint string) bool {
+	var o walkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.skipSelf {
+		return func(string) bool { return false }
+	}
+
+	paths := f.selfPathsWithMountpoints()
+	return func(pathWithMountpoint string) bool {
+		for _, path := range paths {
+			if pathWithMountpoint == path || strings.HasPrefix(pathWithMountpoint, path+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// selfPathsWithMountpoints returns the paths of the cgroups of the agent, joined with the mountpoints of their hierarchies.
+// The root cgroups are not returned.
+func (f *FS) selfPathsWithMountpoints() []string {
+	v1, v2, err := f.selfPaths()
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	if v2 != "" {
+		if path, err := f.PathV2AddMountpoint(v2); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if v1 != "" {
+		// The cgroup1 path is of the named systemd hierarchy, or of the perf_event one if systemd isn't running, see Paths.
+		if path := filepath.Join("/sys/fs/cgroup/systemd", v1); f.exists(path) {
+			paths = append(paths, path)
+		} else if path, err := f.PathV1AddMountpoint("perf_event", v1); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// exists reports whether the path, including the mountpoint, exists in the file system of f.
+func (f *FS) exists(path string) bool {
+	_, err := fs.Stat(f.fsys, rel(path))
+	return err == nil
+}
+
+// selfPaths returns the cgroup1 and cgroup2 paths of the agent, without the mountpoints.
+func (f *FS) selfPaths() (string, string, error) {
+	if f == defaultFS {
+		cg, err := SelfCgroup()
+		return cg.V1Path, cg.V2Path, err
+	}
+	return f.Paths(os.Getpid())
+}
@@ -15,9 +15,7 @@
 package debuginfo
 
 import (
-	"bytes"
 	"context"
-	"debug/elf"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -97,8 +95,6 @@ func (f *Finder) Find(ctx context.Context, root string, obj *objectfile.ObjectFi
 	return file, nil
 }
 
-var errSectionNotFound = errors.New("section not found")
-
 func (f *Finder) find(ctx context.Context, root string, obj *objectfile.ObjectFile) (string, error) {
 	if obj == nil {
 		return "", errors.New("object file is nil")
@@ -139,16 +135,9 @@ func (f *Finder) find(ctx context.Context, root string, obj *objectfile.ObjectFi
 	// The checksum is computed on the debugging information file’s full contents by the function given below,
 	// passing zero as the crc argument.
 
-	ef, err := obj.ELF()
-	if err != nil {
-		return "", fmt.Errorf("failed to read ELF file: %w", err)
-	}
-
-	base, crc, err := readDebuglink(ef)
+	base, crc, _, err := obj.DebugLink()
 	if err != nil {
-		if !errors.Is(err, errSectionNotFound) {
-			level.Debug(f.logger).Log("msg", "failed to read debug links", "err", err)
-		}
+		level.Debug(f.logger).Log("msg", "failed to read debug links", "err", err)
 	}
 
 	files := f.generatePaths(root, obj.BuildID, obj.Path, base)
@@ -188,27 +177,6 @@ func (f *Finder) find(ctx context.Context, root string, obj *objectfile.ObjectFi
 	return "", os.ErrNotExist
 }
 
-func readDebuglink(ef *elf.File) (string, uint32, error) {
-	if sec := ef.Section(".gnu_debuglink"); sec != nil {
-		d, err := sec.Data()
-		if err != nil {
-			return "", 0, err
-		}
-		parts := bytes.Split(d, []byte{0})
-		name := string(parts[0])
-		sum := parts[len(parts)-1]
-		if len(sum) != 4 {
-			return "", 0, errors.New("invalid checksum length")
-		}
-		crc := ef.FileHeader.ByteOrder.Uint32(sum)
-		if crc == 0 {
-			return "", 0, errors.New("invalid checksum")
-		}
-		return name, crc, nil
-	}
-	return "", 0, errSectionNotFound
-}
-
 func (f *Finder) generatePaths(root, buildID, path, filename string) []string {
 	const dbgExt = ".debug"
 	if len(filename) == 0 {
@@ -16,7 +16,6 @@ package debuginfo
 
 import (
 	"context"
-	"debug/elf"
 	"os"
 	"testing"
 
@@ -267,47 +266,3 @@ func TestFinder_generatePaths(t *testing.T) {
 		})
 	}
 }
-
-func Test_readDebuglink(t *testing.T) {
-	type args struct {
-		path string
-	}
-	tests := []struct {
-		name     string
-		args     args
-		wantBase string
-		wantSum  uint32
-		wantErr  bool
-	}{
-		{
-			name: "valid",
-			args: args{
-				path: "testdata/readelf-sections",
-			},
-			wantBase: "readelf-sections.debug",
-			wantSum:  2366737317, // needs to be changed if testdata/generate.sh runs
-			wantErr:  false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			f, err := os.Open(tt.args.path)
-			require.NoError(t, err)
-
-			ef, err := elf.NewFile(f)
-			require.NoError(t, err)
-
-			got, gotSum, err := readDebuglink(ef)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("readDebuglink() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.wantBase {
-				t.Errorf("readDebuglink() got = %v, want %v", got, tt.wantBase)
-			}
-			if gotSum != tt.wantSum {
-				t.Errorf("readDebuglink() gotSum = %v, want %v", gotSum, tt.wantSum)
-			}
-		})
-	}
-}
@@ -210,7 +210,10 @@ func (c *Client) PodToContainers(pod *v1.Pod) []*ContainerDefinition {
 			level.Debug(c.logger).Log("msg", "skipping pod, cannot find cgroup path", "namespace", pod.GetNamespace(), "pod", pod.GetName(), "err", err)
 			continue
 		}
-		cgroupPathV2WithMountpoint, _ := cgroup.PathV2AddMountpoint(cgroupPathV2)
+		cgroupPathV2WithMountpoint, err := cgroup.PathV2AddMountpoint(cgroupPathV2)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "cannot resolve cgroup v2 mountpoint", "namespace", pod.GetNamespace(), "pod", pod.GetName(), "err", err)
+		}
 		cgroupID, _ := cgroup.ID(cgroupPathV2WithMountpoint)
 		mntns, err := namespace.MountNamespaceInode(pid) // linux namespace.
 		if err != nil {
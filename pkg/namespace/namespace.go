@@ -28,7 +28,44 @@ import (
 
 // MountNamespaceInode returns the inode of the mount namespace of the given pid.
 func MountNamespaceInode(pid int) (uint64, error) {
-	fileinfo, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid), "ns/mnt"))
+	return inode(pid, "mnt")
+}
+
+// Namespaces holds the inode numbers of the namespaces of a process.
+// Processes that have the same inode number for a namespace type share that namespace.
+type Namespaces struct {
+	Mnt    uint64
+	PID    uint64
+	Net    uint64
+	Cgroup uint64
+	User   uint64
+}
+
+// ProcessNamespaces returns the inode numbers of the mnt, pid, net, cgroup and user namespaces of the given pid.
+func ProcessNamespaces(pid int) (Namespaces, error) {
+	var (
+		ns  Namespaces
+		err error
+	)
+	for _, n := range []struct {
+		name string
+		ino  *uint64
+	}{
+		{name: "mnt", ino: &ns.Mnt},
+		{name: "pid", ino: &ns.PID},
+		{name: "net", ino: &ns.Net},
+		{name: "cgroup", ino: &ns.Cgroup},
+		{name: "user", ino: &ns.User},
+	} {
+		if *n.ino, err = inode(pid, n.name); err != nil {
+			return Namespaces{}, fmt.Errorf("failed to get %s namespace of %d: %w", n.name, pid, err)
+		}
+	}
+	return ns, nil
+}
+
+func inode(pid int, ns string) (uint64, error) {
+	fileinfo, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid), "ns", ns))
 	if err != nil {
 		return 0, err
 	}
@@ -69,6 +106,26 @@ func FindPIDs(fs fs.FS, pid int) ([]int, error) {
 	return extractPIDsFromLine(line)
 }
 
+// HostPID returns the PID of the given process in the outermost PID namespace,
+// read from the first field of the NSpid line of /proc/<pid>/status.
+// The outermost namespace is the one of the procfs instance, so this is the host PID
+// as long as /proc is the host's, e.g. when the agent shares the host PID namespace.
+// Processes that are not in a nested PID namespace have a single NSpid entry, which is returned as is.
+func HostPID(pid int) (int, error) {
+	return hostPID(&realfs{}, pid)
+}
+
+func hostPID(fs fs.FS, pid int) (int, error) {
+	pids, err := FindPIDs(fs, pid)
+	if err != nil {
+		return 0, err
+	}
+	if len(pids) == 0 {
+		return 0, fmt.Errorf("empty NSpid line found in /proc/%d/status", pid)
+	}
+	return pids[0], nil
+}
+
 func extractPIDsFromLine(line string) ([]int, error) {
 	trimmedLine := strings.TrimPrefix(line, "NSpid:")
 	pidStrings := strings.Fields(trimmedLine)
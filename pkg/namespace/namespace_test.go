@@ -41,6 +41,33 @@ func TestFindNSPid(t *testing.T) {
 	require.Equal(t, []int{25803, 1}, pid)
 }
 
+func TestHostPID(t *testing.T) {
+	fs := testutil.NewFakeFS(map[string][]byte{
+		"/proc/25803/status": mustReadFile("testdata/proc-status"),
+		"/proc/1/status":     []byte("Name:\tsystemd\nNSpid:\t1\n"),
+		"/proc/2/status":     []byte("Name:\tkthreadd\nNSpid:\n"),
+	})
+
+	pid, err := hostPID(fs, 25803)
+	require.NoError(t, err)
+	require.Equal(t, 25803, pid)
+
+	// Not in a nested PID namespace.
+	pid, err = hostPID(fs, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, pid)
+
+	_, err = hostPID(fs, 2)
+	require.Error(t, err)
+
+	_, err = hostPID(fs, 3)
+	require.Error(t, err)
+
+	pid, err = HostPID(os.Getpid())
+	require.NoError(t, err)
+	require.NotZero(t, pid)
+}
+
 func TestExtractPidsFromLine(t *testing.T) {
 	pid, err := extractPIDsFromLine("NSpid:\t25803\t1")
 	require.NoError(t, err)
@@ -48,4 +75,20 @@ func TestExtractPidsFromLine(t *testing.T) {
 	require.Equal(t, []int{25803, 1}, pid)
 }
 
+func TestProcessNamespaces(t *testing.T) {
+	ns, err := ProcessNamespaces(os.Getpid())
+	require.NoError(t, err)
+
+	mnt, err := MountNamespaceInode(os.Getpid())
+	require.NoError(t, err)
+	require.Equal(t, mnt, ns.Mnt)
+	require.NotZero(t, ns.PID)
+	require.NotZero(t, ns.Net)
+	require.NotZero(t, ns.Cgroup)
+	require.NotZero(t, ns.User)
+
+	_, err = ProcessNamespaces(-1)
+	require.Error(t, err)
+}
+
 // TODO(kakkoyun): Add benchmarks for FindNSPIDs.
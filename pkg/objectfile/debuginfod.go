@@ -0,0 +1,173 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/parca-dev/parca-agent/pkg/buildid"
+)
+
+var ErrDebugInfoNotFound = errors.New("debuginfo not found")
+
+const (
+	defaultDebuginfodTimeout = 30 * time.Second
+	// Build IDs that the servers don't have are not requested again for this long.
+	debuginfodNegativeTTL = 10 * time.Minute
+)
+
+// debuginfodURLs returns the debuginfod servers configured with the DEBUGINFOD_URLS environment variable,
+// which is a space separated list of URLs, as used by elfutils.
+func debuginfodURLs() []string {
+	return strings.Fields(os.Getenv("DEBUGINFOD_URLS"))
+}
+
+// FetchDebugInfo fetches the separate debug information file of the given build ID from the debuginfod servers
+// (see WithDebuginfodURLs), when it can't be found locally, e.g. in /usr/lib/debug.
+// The servers are tried in order, and the first file that is an ELF file with the given build ID is opened in the pool.
+// It returns ErrDebugInfoNotFound if none of the servers have it. This result is cached,
+// so the servers are not requested again for the same build ID for a while.
+// The returned reference should be released after use.
+func (p *Pool) FetchDebugInfo(ctx context.Context, buildID string) (*ObjectFile, error) {
+	if _, err := hex.DecodeString(buildID); err != nil || buildID == "" {
+		return nil, fmt.Errorf("invalid build ID %q", buildID)
+	}
+	if len(p.debuginfodURLs) == 0 {
+		return nil, fmt.Errorf("no debuginfod servers configured: %w", ErrDebugInfoNotFound)
+	}
+
+	path := filepath.Join(p.debuginfodDir, buildID+".debug")
+	if key, ok := p.keyCache.Get(path); ok {
+		if obj, err := p.get(key); err == nil {
+			return obj, nil
+		}
+		p.keyCache.Remove(path)
+	}
+	if _, ok := p.debuginfodMisses.Get(buildID); ok {
+		return nil, ErrDebugInfoNotFound
+	}
+
+	val, err, _ := p.sfg.Do(path, func() (interface{}, error) {
+		return p.fetchDebugInfo(ctx, buildID, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*ObjectFile), nil //nolint:forcetypeassert
+}
+
+func (p *Pool) fetchDebugInfo(ctx context.Context, buildID, path string) (*ObjectFile, error) {
+	// Could have been downloaded before, e.g. before a restart.
+	if f, err := os.Open(path); err == nil {
+		return p.NewFileWithBuildID(f, buildID)
+	}
+
+	if err := os.MkdirAll(p.debuginfodDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create debuginfod directory: %w", err)
+	}
+
+	var (
+		errs        error
+		allNotFound = true
+	)
+	for _, server := range p.debuginfodURLs {
+		err := p.download(ctx, server, buildID, path)
+		if err == nil {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open downloaded debuginfo: %w", err)
+			}
+			return p.NewFileWithBuildID(f, buildID)
+		}
+		level.Debug(p.logger).Log("msg", "failed to fetch debuginfo", "server", server, "buildid", buildID, "err", err)
+		errs = errors.Join(errs, err)
+		allNotFound = allNotFound && errors.Is(err, ErrDebugInfoNotFound)
+	}
+	if !allNotFound {
+		// At least one server failed for another reason, so it might still have it.
+		return nil, errs
+	}
+	p.debuginfodMisses.Add(buildID, struct{}{})
+	return nil, ErrDebugInfoNotFound
+}
+
+// download streams the debuginfo of the given build ID from the server to a temporary file,
+// and moves it to path once it is validated.
+func (p *Pool) download(ctx context.Context, server, buildID, path string) error {
+	u, err := url.JoinPath(server, "buildid", buildID, "debuginfo")
+	if err != nil {
+		return fmt.Errorf("invalid debuginfod server URL %s: %w", server, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.debuginfodTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%s: %w", u, ErrDebugInfoNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, u)
+	}
+
+	tmp, err := os.CreateTemp(p.debuginfodDir, buildID+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", u, err)
+	}
+
+	ef, err := elfNewFile(tmp)
+	if err != nil {
+		return fmt.Errorf("debuginfo downloaded from %s is not a valid ELF file: %w", u, err)
+	}
+	got, err := buildid.FromNotes(ef)
+	if err != nil {
+		return fmt.Errorf("failed to get build ID of debuginfo downloaded from %s: %w", u, err)
+	}
+	if got != buildID {
+		return fmt.Errorf("debuginfo downloaded from %s has build ID %q, want %q", u, got, buildID)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move downloaded debuginfo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestPoolFetchDebugInfo(t *testing.T) {
+	const (
+		fibBuildID      = "500018e64aeed6f995bac46ae5d81a30159204a5"
+		missingBuildID  = "deadbeef"
+		mismatchBuildID = "cafebabe"
+	)
+
+	requests := atomic.NewInt64(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		switch r.URL.Path {
+		case "/buildid/" + fibBuildID + "/debuginfo":
+			http.ServeFile(w, r, filepath.Join("testdata", "fib-nopie"))
+		case "/buildid/" + mismatchBuildID + "/debuginfo":
+			http.ServeFile(w, r, filepath.Join("testdata", "fib"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(func() {
+		srv.Close()
+		// Idle connections would be counted as leaked file descriptors by other tests.
+		http.DefaultClient.CloseIdleConnections()
+	})
+
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute,
+		WithDebuginfodURLs([]string{srv.URL}),
+		WithDebuginfodTimeout(time.Second),
+	)
+	objFilePool.debuginfodDir = t.TempDir()
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+	ctx := context.Background()
+
+	obj, err := objFilePool.FetchDebugInfo(ctx, fibBuildID)
+	require.NoError(t, err)
+	require.Equal(t, fibBuildID, obj.BuildID)
+	_, err = obj.ELF()
+	require.NoError(t, err)
+
+	// Cached in the pool.
+	again, err := objFilePool.FetchDebugInfo(ctx, fibBuildID)
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+	require.Equal(t, int64(1), requests.Load())
+
+	// Negative results are cached.
+	_, err = objFilePool.FetchDebugInfo(ctx, missingBuildID)
+	require.ErrorIs(t, err, ErrDebugInfoNotFound)
+	_, err = objFilePool.FetchDebugInfo(ctx, missingBuildID)
+	require.ErrorIs(t, err, ErrDebugInfoNotFound)
+	require.Equal(t, int64(2), requests.Load())
+
+	// Files with a different build ID are rejected.
+	_, err = objFilePool.FetchDebugInfo(ctx, mismatchBuildID)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrDebugInfoNotFound)
+	require.NoFileExists(t, filepath.Join(objFilePool.debuginfodDir, mismatchBuildID+".debug"))
+
+	_, err = objFilePool.FetchDebugInfo(ctx, "../../etc/passwd")
+	require.Error(t, err)
+}
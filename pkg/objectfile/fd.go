@@ -0,0 +1,134 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"golang.org/x/sys/unix"
+)
+
+// ErrTooManyOpenFiles is returned when a file can't be opened, because the process is running out of file descriptors,
+// even after the pool evicted its entries. Callers should back off and try again later.
+var ErrTooManyOpenFiles = errors.New("too many open files")
+
+// fdHighWatermark is the ratio of the open file descriptors to RLIMIT_NOFILE,
+// above which the pool evicts its entries before opening another file.
+const fdHighWatermark = 0.9
+
+// fdSampleInterval is how long a sample of the open file descriptors of the process is used for.
+const fdSampleInterval = time.Second
+
+// processFDs returns the number of open file descriptors of the process and its soft RLIMIT_NOFILE.
+// The limit is 0 if the number of file descriptors is not limited.
+func processFDs() (open, limit int64, err error) { //nolint:nonamedreturns
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, 0, fmt.Errorf("failed to get RLIMIT_NOFILE: %w", err)
+	}
+	if rlim.Cur == 0 || rlim.Cur == unix.RLIM_INFINITY {
+		return 0, 0, nil
+	}
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list open file descriptors: %w", err)
+	}
+	return int64(len(fds)), int64(rlim.Cur), nil
+}
+
+// fdCounter estimates the file descriptor usage of the process, without listing its file descriptors on every open.
+// They are sampled at most once per fdSampleInterval, and the files the pool opens and closes in between
+// are counted on top of the sample.
+type fdCounter struct {
+	// Returns the number of open file descriptors and the limit, replaced in tests.
+	sample func() (open, limit int64, err error)
+
+	mtx       *sync.Mutex
+	sampledAt time.Time
+	open      int64
+	limit     int64
+}
+
+func newFDCounter(sample func() (open, limit int64, err error)) *fdCounter {
+	return &fdCounter{
+		sample: sample,
+		mtx:    &sync.Mutex{},
+	}
+}
+
+// usage returns the ratio of the open file descriptors to the limit.
+func (c *fdCounter) usage() (float64, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if now := time.Now(); now.Sub(c.sampledAt) >= fdSampleInterval {
+		open, limit, err := c.sample()
+		if err != nil {
+			return 0, err
+		}
+		c.open, c.limit, c.sampledAt = open, limit, now
+	}
+	if c.limit <= 0 {
+		return 0, nil
+	}
+	return float64(c.open) / float64(c.limit), nil
+}
+
+// add counts the file descriptors opened, or closed if n is negative, since the last sample.
+func (c *fdCounter) add(n int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.open += n
+}
+
+// ensureFDs makes sure there are file descriptors left to open another file.
+// When the process is nearing its limit, the least recently used entries of the pool are evicted,
+// until enough file descriptors are freed. The evicted entries that are retained, e.g. the pinned ones, stay open.
+// It returns ErrTooManyOpenFiles if evicting the pool doesn't free enough file descriptors.
+func (p *Pool) ensureFDs() error {
+	usage, err := p.fds.usage()
+	if err != nil {
+		// Can't tell, let the open fail if we are out of file descriptors.
+		level.Debug(p.logger).Log("msg", "failed to get file descriptor usage", "err", err)
+		return nil
+	}
+	p.metrics.fdUsage.Set(usage)
+	if usage < fdHighWatermark {
+		return nil
+	}
+
+	level.Debug(p.logger).Log("msg", "running out of file descriptors, evicting object files", "usage", usage)
+	// Every entry is evicted at most once, as the retained ones don't free their file descriptors.
+	for n := p.entries.Load(); n > 0 && usage >= fdHighWatermark; n-- {
+		p.objCache.Evict()
+		usage, err = p.fds.usage()
+		if err != nil {
+			level.Debug(p.logger).Log("msg", "failed to get file descriptor usage", "err", err)
+			return nil
+		}
+	}
+	p.metrics.fdUsage.Set(usage)
+	if usage >= fdHighWatermark {
+		return fmt.Errorf("%w: %.0f%% of RLIMIT_NOFILE is in use", ErrTooManyOpenFiles, usage*100)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ErrDecompressedTooLarge is returned when a compressed object file would be larger than the limit once decompressed,
+// e.g. a gzip bomb, so it doesn't fill the temporary file system.
+var ErrDecompressedTooLarge = errors.New("decompressed object file is too large")
+
+const (
+	// defaultMaxDecompressedSize is the default limit of the size of the decompressed object files.
+	// See WithMaxDecompressedSize.
+	defaultMaxDecompressedSize = 4 << 30
+	// maxGzipRatio is the limit of the ratio of the decompressed size of an object file to its compressed size,
+	// well above what executables and their debuginfo compress to.
+	maxGzipRatio = 32
+)
+
+// isGzip reports whether the file starts with the gzip magic number.
+func isGzip(f *os.File) (bool, error) {
+	magic := make([]byte, len(gzipMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read magic number: %w", err)
+	}
+	return magic[0] == gzipMagic[0] && magic[1] == gzipMagic[1], nil
+}
+
+// gunzip decompresses the gzip compressed file of the given size to a temporary file and returns it with its size.
+// The temporary file is unlinked right away, so it is cleaned up once the returned file is closed,
+// even if the process doesn't get the chance to remove it.
+// It returns ErrDecompressedTooLarge if the decompressed file would be larger than maxSize,
+// or than maxGzipRatio times the compressed size.
+func gunzip(f *os.File, size, maxSize int64) (*os.File, int64, error) {
+	zr, err := gzip.NewReader(io.NewSectionReader(f, 0, math.MaxInt64))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer zr.Close()
+
+	tmp, err := os.CreateTemp("", "parca-agent-gunzip-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if err := os.Remove(tmp.Name()); err != nil {
+		return nil, 0, errors.Join(fmt.Errorf("failed to unlink temporary file: %w", err), tmp.Close())
+	}
+
+	limit := maxSize
+	if size <= limit/maxGzipRatio {
+		limit = size * maxGzipRatio
+	}
+	n, err := io.Copy(tmp, io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, 0, errors.Join(fmt.Errorf("failed to decompress: %w", err), tmp.Close())
+	}
+	if n > limit {
+		return nil, 0, errors.Join(fmt.Errorf("%w: more than %d bytes", ErrDecompressedTooLarge, limit), tmp.Close())
+	}
+	if err := rewind(tmp); err != nil {
+		return nil, 0, errors.Join(err, tmp.Close())
+	}
+	return tmp, n, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-kit/log/level"
+	"golang.org/x/sys/unix"
+)
+
+var ErrSectionCompressed = errors.New("section is compressed")
+
+// mapping is a read-only memory mapping of the whole object file,
+// shared by all the section views of the object file.
+// It is unmapped once the object file is closed and all the views are released.
+type mapping struct {
+	mtx    sync.Mutex
+	data   []byte
+	holds  int
+	closed bool
+}
+
+func (m *mapping) acquire(f *os.File, size int64) ([]byte, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.closed {
+		return nil, ErrAlreadyClosed
+	}
+	if m.data == nil {
+		data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mmap file: %w", err)
+		}
+		m.data = data
+	}
+	m.holds++
+	return m.data, nil
+}
+
+func (m *mapping) release() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.holds--
+	return m.unmapIfUnused()
+}
+
+func (m *mapping) close() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.closed = true
+	return m.unmapIfUnused()
+}
+
+func (m *mapping) unmapIfUnused() error {
+	if !m.closed || m.holds > 0 || m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	if err := unix.Munmap(data); err != nil {
+		return fmt.Errorf("failed to munmap file: %w", err)
+	}
+	return nil
+}
+
+// SectionBytes returns the contents of the named section as a view into a memory mapping of the object file,
+// without copying it. The mapping is shared by all the views of the object file,
+// so goroutines reading different sections of the same file do not contend with each other.
+// The returned release function must be called once the view is no longer needed,
+// and the returned slice must not be used after that: the mapping is unmapped
+// when the object file is closed and all of its views are released.
+// It returns ErrSectionNotFound if the section does not exist or has no data in the file,
+// and ErrSectionCompressed if the section is compressed, as it can't be used without copying.
+func (o *ObjectFile) SectionBytes(name string) ([]byte, func(), error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sec := o.section(ef, name)
+	if sec == nil || sec.Type == elf.SHT_NOBITS {
+		return nil, nil, ErrSectionNotFound
+	}
+	if sec.Flags&elf.SHF_COMPRESSED != 0 {
+		return nil, nil, ErrSectionCompressed
+	}
+	if sec.Offset+sec.FileSize > uint64(o.fileSize) {
+		return nil, nil, fmt.Errorf("section %s is out of the bounds of the file %s", name, o.Path)
+	}
+
+	data, err := o.mapping.acquire(o.file, o.fileSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			if err := o.mapping.release(); err != nil {
+				level.Debug(o.p.logger).Log("msg", "failed to release section view", "path", o.Path, "err", err)
+			}
+		})
+	}
+	return data[sec.Offset : sec.Offset+sec.FileSize : sec.Offset+sec.FileSize], release, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectionBytes(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+	ef, err := obj.ELF()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for _, name := range []string{".text", ".symtab", ".gnu_debuglink"} {
+		want, err := ef.Section(name).Data()
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func(name string, want []byte) {
+			defer wg.Done()
+
+			got, release, err := obj.SectionBytes(name)
+			require.NoError(t, err)
+			defer release()
+			require.Equal(t, want, got)
+		}(name, want)
+	}
+	wg.Wait()
+
+	_, _, err = obj.SectionBytes(".bss")
+	require.ErrorIs(t, err, ErrSectionNotFound)
+	_, _, err = obj.SectionBytes(".does-not-exist")
+	require.ErrorIs(t, err, ErrSectionNotFound)
+
+	_, release, err := obj.SectionBytes(".text")
+	require.NoError(t, err)
+	release()
+	release() // Releasing twice is a no-op.
+
+	// The mapping is kept alive by the outstanding view after the file is closed.
+	data, release, err := obj.SectionBytes(".text")
+	require.NoError(t, err)
+	require.NoError(t, obj.close())
+	require.NotNil(t, obj.mapping.data)
+	require.Equal(t, ef.Section(".text").Size, uint64(len(data)))
+	_ = data[len(data)-1] // Still mapped.
+	release()
+	require.Nil(t, obj.mapping.data)
+}
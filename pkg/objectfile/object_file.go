@@ -15,15 +15,24 @@
 package objectfile
 
 import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/dwarf"
 	"debug/elf"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-kit/log/level"
+	"github.com/ianlancetaylor/demangle"
 	"go.uber.org/atomic"
 )
 
@@ -33,20 +42,50 @@ type ObjectFile struct {
 	p *Pool
 
 	BuildID string
+	// SyntheticBuildID is true if the file does not have a Go or GNU build ID note,
+	// and BuildID is derived from the contents of its .text section instead (see buildid.FromText).
+	// As it is content-derived, identical binaries at different paths get the same build ID,
+	// so they are deduplicated downstream, while they still have their own entries in the pool.
+	SyntheticBuildID bool
 
-	Path     string
+	Path string
+	// RealPath is Path with its symbolic links resolved, e.g. /usr/lib/libssl.so.3 for /usr/lib/libssl.so.
+	// It is the same as Path if the path isn't a link, or it couldn't be resolved.
+	RealPath string
+	// Size and Modtime are of the file on disk.
 	Size     int64
 	Modtime  time.Time
 	openedAt time.Time
+	// Last time the object file was opened or shared by the pool, see RetentionPolicy.
+	lastAccess *atomic.Time
+	// Size of the file that is read, which is different from Size if the file is compressed on disk.
+	fileSize int64
 
 	// ELF file is read using ReaderAt,
 	// which means concurrent reads are allowed.
 	elf *elf.File
 	// Read using io.SectionReader,
 	// which means concurrent reads are allowed.
-	file     *os.File
+	file *os.File
+	// Guards closing the file against the readers checking whether it is closed.
+	closeMtx sync.RWMutex
 	closed   *atomic.Bool
-	closedBy *runtime.Frames // Stack trace of the first Close call.
+	closedBy []uintptr // Stack trace of the first Close call.
+	// Lazily created by SectionBytes and shared by all the section views.
+	mapping mapping
+
+	// Sections by name, lazily built on the first section lookup.
+	sectionsOnce sync.Once
+	sections     map[string]*elf.Section
+
+	// Function symbols sorted by address, lazily loaded by SymbolForAddr.
+	symbolsMtx sync.Mutex
+	symbols    []elf.Symbol
+
+	// Parsed DWARF data, shared by the users of NewDWARF until the last one releases it.
+	dwarfMtx  sync.Mutex
+	dwarf     *dwarf.Data
+	dwarfRefs int
 
 	// If exists, will be released when the parent ObjectFile is released.
 	// Go GC with a finalizer works correctly even with cyclic references.
@@ -54,15 +93,27 @@ type ObjectFile struct {
 }
 
 var (
-	ErrNotInitialized = errors.New("file is not initialized")
-	ErrAlreadyClosed  = errors.New("file is already closed")
+	ErrNotInitialized  = errors.New("file is not initialized")
+	ErrAlreadyClosed   = errors.New("file is already closed")
+	ErrSegmentNotFound = errors.New("segment not found")
+	ErrSectionNotFound = errors.New("section not found")
+	ErrFileChanged     = errors.New("file has changed on disk")
+	ErrSymbolNotFound  = errors.New("symbol not found")
+	// ErrTruncatedELF is returned when the ELF file is shorter than its headers say,
+	// e.g. because it is still being written, so opening it could succeed later.
+	ErrTruncatedELF = errors.New("ELF file is truncated")
 )
 
 // Reader returns a reader for the file.
-// Parallel reads are NOT allowed. The caller must call the returned function when done with the reader.
+// Each call returns a new reader with its own offset, backed by ReadAt on the shared file descriptor,
+// so concurrent callers do not need additional file handles and do not serialize on each other.
+// A single returned reader must not be used concurrently.
 func (o *ObjectFile) Reader() (*io.SectionReader, error) {
+	o.closeMtx.RLock()
+	defer o.closeMtx.RUnlock()
+
 	if o.closed.Load() {
-		return nil, errors.Join(ErrAlreadyClosed, fmt.Errorf("file %s is already closed (try increasing `--object-file-pool-size`) it was closed by: %s", o.Path, frames(o.closedBy)))
+		return nil, o.errAlreadyClosed()
 	}
 
 	if o.file == nil {
@@ -70,14 +121,18 @@ func (o *ObjectFile) Reader() (*io.SectionReader, error) {
 		return nil, ErrNotInitialized
 	}
 
-	return io.NewSectionReader(o.file, 0, o.Size), nil
+	return io.NewSectionReader(o.file, 0, o.fileSize), nil
 }
 
 // ELF returns the ELF file for the object file.
 // Parallel reads are allowed.
+// Once the object file is closed, reads from an ELF file returned before fail with os.ErrClosed.
 func (o *ObjectFile) ELF() (*elf.File, error) {
+	o.closeMtx.RLock()
+	defer o.closeMtx.RUnlock()
+
 	if o.closed.Load() {
-		return nil, errors.Join(ErrAlreadyClosed, fmt.Errorf("file %s is already closed (try increasing `--object-file-pool-size`) it was closed by: %s", o.Path, frames(o.closedBy)))
+		return nil, o.errAlreadyClosed()
 	}
 
 	if o.elf == nil || o.Path == "" {
@@ -88,6 +143,544 @@ func (o *ObjectFile) ELF() (*elf.File, error) {
 	return o.elf, nil
 }
 
+// IsClosed reports whether the object file has been closed, e.g. because it has been evicted from the pool.
+// It doesn't block, so the object file could be closed right after it returns false.
+func (o *ObjectFile) IsClosed() bool {
+	return o.closed.Load()
+}
+
+// Validate checks whether the file backing the object file has been changed on disk since it was opened.
+// It compares the size and the modification time of the file with the ones recorded when it was opened.
+func (o *ObjectFile) Validate() error {
+	stat, err := os.Stat(o.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get stats of the file: %w", err)
+	}
+	return o.validate(stat)
+}
+
+func (o *ObjectFile) validate(stat fs.FileInfo) error {
+	if stat.Size() != o.Size || !stat.ModTime().Equal(o.Modtime) {
+		return errors.Join(ErrFileChanged, fmt.Errorf("file %s has changed: size %d -> %d, modtime %s -> %s", o.Path, o.Size, stat.Size(), o.Modtime, stat.ModTime()))
+	}
+	return nil
+}
+
+// HasSection reports whether the ELF file for the object file has a section with the given name.
+// It returns false if the object file is already closed.
+func (o *ObjectFile) HasSection(name string) bool {
+	ef, err := o.ELF()
+	if err != nil {
+		return false
+	}
+	return o.section(ef, name) != nil
+}
+
+// section is like ef.Section, but looks the section up in a map built on first use,
+// instead of walking all the sections on each lookup, e.g. for the lookups of the hot symbolization paths.
+// The ELF file of an object file never changes, files changed on disk are opened as new object files.
+func (o *ObjectFile) section(ef *elf.File, name string) *elf.Section {
+	o.sectionsOnce.Do(func() {
+		o.sections = make(map[string]*elf.Section, len(ef.Sections))
+		for _, sec := range ef.Sections {
+			// As with ef.Section, the first section with the name wins.
+			if _, ok := o.sections[sec.Name]; !ok {
+				o.sections[sec.Name] = sec
+			}
+		}
+	})
+	return o.sections[name]
+}
+
+// SectionNames returns the names of the sections of the ELF file for the object file.
+// It returns nil if the object file is already closed.
+func (o *ObjectFile) SectionNames() []string {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(ef.Sections))
+	for _, s := range ef.Sections {
+		if s.Name == "" {
+			continue
+		}
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Capability describes the best symbolization information an object file has.
+type Capability int
+
+const (
+	// CapabilityNone means the object file doesn't have any symbols, only addresses can be reported.
+	CapabilityNone Capability = iota
+	// CapabilityDynsymOnly means the object file only has the dynamic symbols, i.e. the exported functions,
+	// as stripped binaries do. The debuginfo should be fetched, e.g. from debuginfod.
+	CapabilityDynsymOnly
+	// CapabilitySymtabOnly means the object file has a symbol table, which has function names but no line numbers.
+	CapabilitySymtabOnly
+	// CapabilityGoPCLnTab means the object file is a Go binary with its symbol and line number table.
+	CapabilityGoPCLnTab
+	// CapabilityFullDWARF means the object file has DWARF debug information, with line numbers and inlined functions.
+	CapabilityFullDWARF
+)
+
+func (c Capability) String() string {
+	switch c {
+	case CapabilityDynsymOnly:
+		return "dynsym_only"
+	case CapabilitySymtabOnly:
+		return "symtab_only"
+	case CapabilityGoPCLnTab:
+		return "gopclntab"
+	case CapabilityFullDWARF:
+		return "full_dwarf"
+	default:
+		return "none"
+	}
+}
+
+// SymbolizationCapability returns the best symbolization information the object file has,
+// e.g. to decide whether its debuginfo should be fetched or whether only addresses can be reported.
+// It only checks the section headers, which are already parsed, so it is cheap.
+// It returns CapabilityNone if the object file is already closed.
+func (o *ObjectFile) SymbolizationCapability() Capability {
+	ef, err := o.ELF()
+	if err != nil {
+		return CapabilityNone
+	}
+
+	has := func(names ...string) bool {
+		for _, name := range names {
+			// Sections of separate debug files that are stripped out have no data.
+			if sec := o.section(ef, name); sec != nil && sec.Type != elf.SHT_NOBITS {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has(".debug_info", ".zdebug_info"):
+		return CapabilityFullDWARF
+	case has(".gopclntab"):
+		return CapabilityGoPCLnTab
+	case has(".symtab"):
+		return CapabilitySymtabOnly
+	case has(".dynsym"):
+		return CapabilityDynsymOnly
+	default:
+		return CapabilityNone
+	}
+}
+
+// GoPCLnTab returns the contents and the virtual address of the Go symbol and line number table.
+// The table is read from the .gopclntab section, or, for some PIE builds that do not have the section,
+// from the region between the runtime.pclntab and runtime.epclntab symbols.
+// It returns ErrSectionNotFound if the object file does not have the table (e.g. it's not a Go binary).
+func (o *ObjectFile) GoPCLnTab() ([]byte, uint64, error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if sec := o.section(ef, ".gopclntab"); sec != nil {
+		data, err := sec.Data()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read .gopclntab section: %w", err)
+		}
+		return data, sec.Addr, nil
+	}
+
+	syms, err := ef.Symbols()
+	if err != nil {
+		if errors.Is(err, elf.ErrNoSymbols) {
+			return nil, 0, ErrSectionNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to read symbols: %w", err)
+	}
+	var start, end uint64
+	for _, sym := range syms {
+		switch sym.Name {
+		case "runtime.pclntab":
+			start = sym.Value
+		case "runtime.epclntab":
+			end = sym.Value
+		}
+	}
+	if start == 0 || end <= start {
+		return nil, 0, ErrSectionNotFound
+	}
+	for _, sec := range ef.Sections {
+		if sec.Type == elf.SHT_NOBITS || start < sec.Addr || end > sec.Addr+sec.Size {
+			continue
+		}
+		data := make([]byte, end-start)
+		if _, err := sec.ReadAt(data, int64(start-sec.Addr)); err != nil {
+			return nil, 0, fmt.Errorf("failed to read runtime.pclntab from %s section: %w", sec.Name, err)
+		}
+		return data, start, nil
+	}
+	return nil, 0, ErrSectionNotFound
+}
+
+// GoBuildInfo returns the build information embedded in the .go.buildinfo section of Go binaries,
+// e.g. the main module path and version, and the VCS revision and time the binary was built from.
+func (o *ObjectFile) GoBuildInfo() (*debug.BuildInfo, error) {
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := buildinfo.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Go build info of %s: %w", o.Path, err)
+	}
+	return info, nil
+}
+
+// SymbolForAddr returns the name and the start address of the function symbol enclosing the given address.
+// The address is expected to be normalized to the virtual address space of the ELF file.
+// Symbols are read from .symtab, falling back to .dynsym, and are cached on first use.
+// Addresses in the PLT resolve to the symbol the PLT entry jumps to, with a @plt suffix, e.g. printf@plt.
+// It returns ErrSymbolNotFound if no function symbol contains the address.
+func (o *ObjectFile) SymbolForAddr(addr uint64) (string, uint64, error) {
+	syms, err := o.funcSymbols()
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Find the last symbol that starts at or before the address.
+	i := sort.Search(len(syms), func(i int) bool { return syms[i].Value > addr }) - 1
+	if i < 0 {
+		return "", 0, ErrSymbolNotFound
+	}
+	sym := syms[i]
+	// Symbols without a size are assumed to extend to the next symbol.
+	if sym.Size != 0 && addr >= sym.Value+sym.Size {
+		return "", 0, ErrSymbolNotFound
+	}
+	return sym.Name, sym.Value, nil
+}
+
+// Symbol is a function symbol of an object file.
+type Symbol struct {
+	// Name is the name of the symbol as it appears in the symbol table, e.g. mangled for C++ and Rust.
+	Name string
+	// Demangled is the human-readable name of Itanium C++ and Rust symbols.
+	// It is the same as Name if the symbol is not mangled or can't be demangled.
+	Demangled string
+	// Start is the address of the symbol.
+	Start uint64
+}
+
+// DemangledSymbolForAddr is like SymbolForAddr, but also demangles the name of C++ and Rust symbols.
+// The raw name is kept, so callers can still match symbols by it.
+func (o *ObjectFile) DemangledSymbolForAddr(addr uint64) (Symbol, error) {
+	name, start, err := o.SymbolForAddr(addr)
+	if err != nil {
+		return Symbol{}, err
+	}
+	return Symbol{
+		Name:      name,
+		Demangled: demangle.Filter(name, demangle.NoClones),
+		Start:     start,
+	}, nil
+}
+
+func (o *ObjectFile) funcSymbols() ([]elf.Symbol, error) {
+	o.symbolsMtx.Lock()
+	defer o.symbolsMtx.Unlock()
+
+	if o.symbols != nil {
+		return o.symbols, nil
+	}
+
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, err
+	}
+	syms, err := ef.Symbols()
+	if errors.Is(err, elf.ErrNoSymbols) {
+		syms, err = ef.DynamicSymbols()
+	}
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("failed to read symbols: %w", err)
+	}
+
+	funcs := make([]elf.Symbol, 0, len(syms))
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 {
+			continue
+		}
+		funcs = append(funcs, sym)
+	}
+	plt, err := pltSymbols(ef)
+	if err != nil {
+		level.Debug(o.p.logger).Log("msg", "failed to read PLT symbols", "path", o.Path, "err", err)
+	}
+	funcs = append(funcs, plt...)
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].Value < funcs[j].Value })
+	o.symbols = funcs
+	return funcs, nil
+}
+
+// ProgramHeaders returns the program headers of the ELF file for the object file.
+func (o *ObjectFile) ProgramHeaders() ([]elf.ProgHeader, error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, err
+	}
+
+	phdrs := make([]elf.ProgHeader, 0, len(ef.Progs))
+	for _, p := range ef.Progs {
+		phdrs = append(phdrs, p.ProgHeader)
+	}
+	return phdrs, nil
+}
+
+// EHFrameHeader returns the PT_GNU_EH_FRAME segment of the ELF file for the object file.
+// It returns ErrSegmentNotFound if the object file does not have one.
+func (o *ObjectFile) EHFrameHeader() (*elf.Prog, error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range ef.Progs {
+		if p.Type == elf.PT_GNU_EH_FRAME {
+			return p, nil
+		}
+	}
+	return nil, ErrSegmentNotFound
+}
+
+// EHFrameInfo reports whether the object file has call frame information in .eh_frame,
+// and the number of frame description entries (FDEs) in it.
+// The number is read from the header of the binary search table of .eh_frame_hdr, without parsing the CFI,
+// so it is 0 if the object file has no .eh_frame_hdr section or its table is omitted.
+// Binaries compiled with -fno-asynchronous-unwind-tables are reported as not present.
+func (o *ObjectFile) EHFrameInfo() (present bool, fdeCount int, err error) { //nolint:nonamedreturns
+	ef, err := o.ELF()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if sec := o.section(ef, ".eh_frame"); sec == nil || sec.Type == elf.SHT_NOBITS || sec.Size == 0 {
+		return false, 0, nil
+	}
+	hdr := o.section(ef, ".eh_frame_hdr")
+	if hdr == nil || hdr.Type == elf.SHT_NOBITS {
+		return true, 0, nil
+	}
+
+	// The header is: version, eh_frame_ptr encoding, fde_count encoding, table encoding,
+	// followed by the encoded eh_frame_ptr and fde_count. The encoded values take at most 8 bytes each,
+	// except for LEB128 values, which are not used in practice.
+	buf := make([]byte, 4+8+8)
+	n, err := hdr.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, 0, fmt.Errorf("failed to read .eh_frame_hdr section: %w", err)
+	}
+	buf = buf[:n]
+	if len(buf) < 4 || buf[0] != 1 {
+		return false, 0, errors.New("invalid .eh_frame_hdr section: unsupported version")
+	}
+	ptrEnc, countEnc := buf[1], buf[2]
+	if countEnc == dwEHPEOmit {
+		return true, 0, nil
+	}
+
+	ptrSize, ok := dwEHPESize(ptrEnc, ef.Class)
+	if !ok {
+		return false, 0, fmt.Errorf("invalid .eh_frame_hdr section: unsupported eh_frame_ptr encoding %#x", ptrEnc)
+	}
+	countSize, ok := dwEHPESize(countEnc, ef.Class)
+	if !ok {
+		return false, 0, fmt.Errorf("invalid .eh_frame_hdr section: unsupported fde_count encoding %#x", countEnc)
+	}
+	off := 4 + ptrSize
+	if len(buf) < off+countSize {
+		return false, 0, errors.New("invalid .eh_frame_hdr section: truncated header")
+	}
+	var count uint64
+	switch countSize {
+	case 2:
+		count = uint64(ef.ByteOrder.Uint16(buf[off:]))
+	case 4:
+		count = uint64(ef.ByteOrder.Uint32(buf[off:]))
+	case 8:
+		count = ef.ByteOrder.Uint64(buf[off:])
+	}
+	return true, int(count), nil
+}
+
+const dwEHPEOmit = 0xff
+
+// dwEHPESize returns the size of a value with the given DW_EH_PE pointer encoding.
+// Only the fixed size formats are supported.
+func dwEHPESize(enc byte, class elf.Class) (int, bool) {
+	switch enc & 0x0f {
+	case 0x00: // DW_EH_PE_absptr
+		if class == elf.ELFCLASS32 {
+			return 4, true
+		}
+		return 8, true
+	case 0x02, 0x0a: // DW_EH_PE_udata2, DW_EH_PE_sdata2
+		return 2, true
+	case 0x03, 0x0b: // DW_EH_PE_udata4, DW_EH_PE_sdata4
+		return 4, true
+	case 0x04, 0x0c: // DW_EH_PE_udata8, DW_EH_PE_sdata8
+		return 8, true
+	default:
+		return 0, false
+	}
+}
+
+// TextSection returns the virtual address, the file offset and the size of the .text section of the object file,
+// e.g. to convert an instruction pointer into a file offset for offset-based symbolization.
+// It returns ErrSectionNotFound if the object file does not have a .text section.
+func (o *ObjectFile) TextSection() (addr, offset, size uint64, err error) { //nolint:nonamedreturns
+	ef, err := o.ELF()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	sec := o.section(ef, ".text")
+	if sec == nil || sec.Type == elf.SHT_NOBITS {
+		return 0, 0, 0, ErrSectionNotFound
+	}
+	return sec.Addr, sec.Offset, sec.Size, nil
+}
+
+// Needed returns the sonames of the shared libraries the object file depends on, e.g. libc.so.6,
+// read from the DT_NEEDED entries of its .dynamic section, in the order they are listed.
+// Statically linked object files don't have any.
+func (o *ObjectFile) Needed() ([]string, error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, err
+	}
+
+	libs, err := ef.ImportedLibraries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DT_NEEDED entries of %s: %w", o.Path, err)
+	}
+	return libs, nil
+}
+
+// RunPaths returns the library search paths of the object file, read from the DT_RPATH and DT_RUNPATH entries
+// of its .dynamic section, in the order they are searched by the dynamic linker.
+// Dynamic string tokens, e.g. $ORIGIN, are not expanded, it's up to the caller to expand them
+// (e.g. $ORIGIN against the directory of the object file).
+// If both are set, the dynamic linker ignores DT_RPATH.
+func (o *ObjectFile) RunPaths() (rpath, runpath []string, err error) { //nolint:nonamedreturns
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rpath, err = dynPaths(ef, elf.DT_RPATH)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read DT_RPATH entries of %s: %w", o.Path, err)
+	}
+	runpath, err = dynPaths(ef, elf.DT_RUNPATH)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read DT_RUNPATH entries of %s: %w", o.Path, err)
+	}
+	return rpath, runpath, nil
+}
+
+// dynPaths returns the paths of the colon separated lists of the given dynamic tag.
+func dynPaths(ef *elf.File, tag elf.DynTag) ([]string, error) {
+	lists, err := ef.DynString(tag)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, list := range lists {
+		for _, path := range strings.Split(list, ":") {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// NewDWARF returns the DWARF data of the object file, with a function to release it once it is not used anymore.
+// The DWARF data is parsed once and shared by the concurrent callers, instead of being parsed by each of them,
+// and it is dropped when the last of them releases it. The release function is safe to call more than once.
+// The returned data must be treated as read-only: each goroutine should create its own readers (e.g. with Reader or LineReader),
+// and Type must not be called concurrently, as it is not safe for concurrent use.
+func (o *ObjectFile) NewDWARF() (*dwarf.Data, func() error, error) {
+	ef, err := o.ELF()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o.dwarfMtx.Lock()
+	defer o.dwarfMtx.Unlock()
+
+	if o.dwarf == nil {
+		d, err := ef.DWARF()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read DWARF data of %s: %w", o.Path, err)
+		}
+		o.dwarf = d
+	}
+	o.dwarfRefs++
+
+	var once sync.Once
+	release := func() error {
+		once.Do(func() {
+			o.dwarfMtx.Lock()
+			defer o.dwarfMtx.Unlock()
+
+			o.dwarfRefs--
+			if o.dwarfRefs == 0 {
+				o.dwarf = nil
+			}
+		})
+		return nil
+	}
+	return o.dwarf, release, nil
+}
+
+// DebugLink returns the name of the separate debug file and its CRC32 checksum,
+// read from the .gnu_debuglink section of the object file.
+// It returns ok=false if the object file does not have a debug link.
+func (o *ObjectFile) DebugLink() (name string, crc uint32, ok bool, err error) { //nolint:nonamedreturns
+	ef, err := o.ELF()
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	sec := o.section(ef, ".gnu_debuglink")
+	if sec == nil {
+		return "", 0, false, nil
+	}
+	d, err := sec.Data()
+	if err != nil {
+		return "", 0, false, fmt.Errorf("failed to read .gnu_debuglink section: %w", err)
+	}
+
+	// The section contains:
+	// - a filename, with any leading directory components removed, followed by a zero byte,
+	// - zero to three bytes of padding, as needed to reach the next four-byte boundary within the section, and
+	// - a four-byte CRC checksum, stored in the same endianness used for the executable file itself.
+	end := bytes.IndexByte(d, 0)
+	if end <= 0 {
+		return "", 0, false, errors.New("invalid .gnu_debuglink section: missing file name")
+	}
+	off := (end + 4) &^ 3
+	if len(d) < off+4 {
+		return "", 0, false, errors.New("invalid .gnu_debuglink section: missing checksum")
+	}
+	return string(d[:end]), ef.ByteOrder.Uint32(d[off : off+4]), true, nil
+}
+
 // close closes the underlying file descriptor.
 // It is safe to call this function multiple times.
 // File should only be closed once.
@@ -100,37 +693,100 @@ func (o *ObjectFile) close() error {
 	}
 	o.p.metrics.closeAttempts.Inc()
 
-	if o.closed.Load() {
+	o.closeMtx.Lock()
+	defer o.closeMtx.Unlock()
+
+	if !o.closed.CompareAndSwap(false, true) {
 		return errors.Join(ErrAlreadyClosed, fmt.Errorf("file %s is already closed by: %s", o.Path, frames(o.closedBy)))
 	}
-	o.closed.Store(true)
-	// NOTICE: This close is a no-op. The elf.File is opened through elf.NewFile,
-	// which does not initialize a closer. It's here because of testing purposes.
-	// Because of this the underlying file descriptor will be closed by the GC.
-	// If there is an active reader, it will conclude successfully.
-	// Only downside will be to re-opening the file if the ObjectFile is evicted
-	// from the pool.
+	o.closedBy = callers()
+	o.p.trackClose(o)
+	// The mapping outlives the file descriptor, it's unmapped once the last section view is released.
+	if err := o.mapping.close(); err != nil {
+		level.Debug(o.p.logger).Log("msg", "failed to unmap object file", "path", o.Path, "err", err)
+	}
+
+	// NOTICE: The elf.File is opened through elf.NewFile, which does not initialize a closer,
+	// so this close is a no-op and the ELF file doesn't own the underlying file descriptor.
+	// It's here in case the ELF file is opened with elf.Open (e.g. in tests).
+	var errs error
 	if err := o.elf.Close(); err != nil {
+		errs = errors.Join(errs, err)
+	}
+	// The file descriptor is owned by the object file, so close it here instead of relying on the GC.
+	// Readers that are still in use will fail with os.ErrClosed.
+	if o.file != nil {
+		if err := o.file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = errors.Join(errs, err)
+		}
+	}
+	if errs != nil {
 		o.p.metrics.closed.WithLabelValues(lvError).Inc()
 		o.p.metrics.keptOpenDuration.Observe(time.Since(o.openedAt).Seconds())
-		return err
+		return errs
 	}
 
 	// Successfully closed the file.
-	o.closedBy = callers()
 	o.p.metrics.closed.WithLabelValues(lvSuccess).Inc()
+	o.p.logEvent("object file closed", o)
 	o.p.metrics.open.Dec()
 	o.p.metrics.keptOpenDuration.Observe(time.Since(o.openedAt).Seconds())
 
 	return nil
 }
 
+// checkTruncated checks that the data of the sections and the segments of the ELF file are within its size,
+// as the ELF file can be parsed successfully if only its headers have been written.
+func checkTruncated(ef *elf.File, size int64) error {
+	for _, sec := range ef.Sections {
+		if sec.Type == elf.SHT_NOBITS || sec.Type == elf.SHT_NULL {
+			continue
+		}
+		if end := sec.Offset + sec.FileSize; end > uint64(size) {
+			return fmt.Errorf("%w: section %s ends at %d, file size is %d", ErrTruncatedELF, sec.Name, end, size)
+		}
+	}
+	for _, prog := range ef.Progs {
+		if end := prog.Off + prog.Filesz; end > uint64(size) {
+			return fmt.Errorf("%w: segment %s ends at %d, file size is %d", ErrTruncatedELF, prog.Type, end, size)
+		}
+	}
+	return nil
+}
+
+// IsELFPath reports whether the file at the given path starts with the ELF magic number.
+// Files that are too short to contain the magic number are reported as not ELF.
+func IsELFPath(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read ELF magic number of %s: %w", path, err)
+	}
+	return string(magic) == elf.ELFMAG, nil
+}
+
 func rewind(f io.ReadSeeker) error {
 	_, err := f.Seek(0, io.SeekStart)
 	return err
 }
 
-func callers() *runtime.Frames {
+// errAlreadyClosed returns ErrAlreadyClosed along with the stack trace of the first close call.
+// closeMtx must be held.
+func (o *ObjectFile) errAlreadyClosed() error {
+	return errors.Join(ErrAlreadyClosed, fmt.Errorf("file %s is already closed (try increasing `--object-file-pool-size`) it was closed by: %s", o.Path, frames(o.closedBy)))
+}
+
+// callers returns the program counters of the stack trace of the caller.
+// The frames are only resolved when they are printed, as the stack trace of most close calls is never printed.
+func callers() []uintptr {
 	var (
 		pcs = make([]uintptr, 20)
 		n   = runtime.Callers(1, pcs)
@@ -138,13 +794,15 @@ func callers() *runtime.Frames {
 	if n == 0 {
 		return nil
 	}
-	return runtime.CallersFrames(pcs[:n])
+	return pcs[:n]
 }
 
-func frames(frames *runtime.Frames) string {
-	if frames == nil {
+func frames(pcs []uintptr) string {
+	if len(pcs) == 0 {
 		return ""
 	}
+	// Frames is an iterator, so it is created for every call to be safe to use concurrently.
+	frames := runtime.CallersFrames(pcs)
 	builder := strings.Builder{}
 	for {
 		frame, more := frames.Next()
@@ -14,13 +14,17 @@
 package objectfile
 
 import (
+	"debug/dwarf"
 	"debug/elf"
+	"debug/gosym"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -73,3 +77,539 @@ func TestOpenELF(t *testing.T) {
 		}
 	})
 }
+
+func TestProgramHeaders(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, 0)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	phdrs, err := obj.ProgramHeaders()
+	require.NoError(t, err)
+	require.NotEmpty(t, phdrs)
+
+	var loads int
+	for _, p := range phdrs {
+		if p.Type == elf.PT_LOAD {
+			loads++
+		}
+	}
+	require.Positive(t, loads)
+
+	ehFrameHdr, err := obj.EHFrameHeader()
+	require.NoError(t, err)
+	require.Equal(t, elf.PT_GNU_EH_FRAME, ehFrameHdr.Type)
+}
+
+func TestEHFrameInfo(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	for name, want := range map[string]int{"fib": 6, "fib-nopie": 5, "nobuildid-a": 4} {
+		obj, err := objFilePool.Open(filepath.Join("./testdata", name))
+		require.NoError(t, err)
+
+		present, fdeCount, err := obj.EHFrameInfo()
+		require.NoError(t, err)
+		require.True(t, present, name)
+		require.Equal(t, want, fdeCount, name)
+	}
+
+	// Go binaries don't have .eh_frame.
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+	present, fdeCount, err := obj.EHFrameInfo()
+	require.NoError(t, err)
+	require.False(t, present)
+	require.Zero(t, fdeCount)
+}
+
+func TestTextSection(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	addr, offset, size, err := obj.TextSection()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x1060), addr)
+	require.Equal(t, uint64(0x1060), offset)
+	require.Equal(t, uint64(0x17e), size)
+
+	require.NoError(t, obj.close())
+	_, _, _, err = obj.TextSection()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	path := filepath.Join("./testdata", "fib")
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	obj, err := objFilePool.Open(path)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r, err := obj.Reader()
+			require.NoError(t, err)
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentELFAndClose(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			for j := 0; j < 100; j++ {
+				ef, err := obj.ELF()
+				if err != nil {
+					require.ErrorIs(t, err, ErrAlreadyClosed)
+					continue
+				}
+				// The file could be closed in between, reads must fail instead of using a stale file.
+				if _, err := ef.Section(".text").Data(); err != nil {
+					require.ErrorIs(t, err, os.ErrClosed)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			if err := obj.close(); err != nil {
+				require.ErrorIs(t, err, ErrAlreadyClosed)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	_, err = obj.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestNeeded(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	needed, err := obj.Needed()
+	require.NoError(t, err)
+	require.Equal(t, []string{"libc.so.6"}, needed)
+
+	static, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+	needed, err = static.Needed()
+	require.NoError(t, err)
+	require.Empty(t, needed)
+
+	require.NoError(t, obj.close())
+	_, err = obj.Needed()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestRunPaths(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	// Linked with -Wl,--enable-new-dtags,-rpath,'$ORIGIN/../lib:/opt/parca/lib'.
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "runpath"))
+	require.NoError(t, err)
+	rpath, runpath, err := obj.RunPaths()
+	require.NoError(t, err)
+	require.Empty(t, rpath)
+	require.Equal(t, []string{"$ORIGIN/../lib", "/opt/parca/lib"}, runpath)
+
+	// Linked with -Wl,--disable-new-dtags,-rpath,'$ORIGIN/../lib'.
+	obj, err = objFilePool.Open(filepath.Join("./testdata", "rpath"))
+	require.NoError(t, err)
+	rpath, runpath, err = obj.RunPaths()
+	require.NoError(t, err)
+	require.Equal(t, []string{"$ORIGIN/../lib"}, rpath)
+	require.Empty(t, runpath)
+
+	obj, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	rpath, runpath, err = obj.RunPaths()
+	require.NoError(t, err)
+	require.Empty(t, rpath)
+	require.Empty(t, runpath)
+}
+
+func TestNewDWARF(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "exe_linux_64"))
+	require.NoError(t, err)
+
+	const n = 8
+	var (
+		wg       sync.WaitGroup
+		datas    [n]*dwarf.Data
+		releases [n]func() error
+		errs     [n]error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			datas[i], releases[i], errs[i] = obj.NewDWARF()
+		}(i)
+	}
+	wg.Wait()
+
+	// Parsed once, and shared.
+	for i, d := range datas {
+		require.NoError(t, errs[i])
+		require.Same(t, datas[0], d)
+	}
+	// Each user reads with its own reader.
+	e, err := datas[0].Reader().Next()
+	require.NoError(t, err)
+	require.Equal(t, dwarf.TagCompileUnit, e.Tag)
+
+	for _, release := range releases[1:] {
+		require.NoError(t, release())
+	}
+	// Releasing twice doesn't drop the data of others.
+	require.NoError(t, releases[1]())
+	d, release, err := obj.NewDWARF()
+	require.NoError(t, err)
+	require.Same(t, datas[0], d)
+	require.NoError(t, release())
+
+	// Dropped once the last user releases it.
+	require.NoError(t, releases[0]())
+	d, release, err = obj.NewDWARF()
+	require.NoError(t, err)
+	require.NotSame(t, datas[0], d)
+	require.NoError(t, release())
+
+	noDWARF, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	_, _, err = noDWARF.NewDWARF()
+	require.Error(t, err)
+
+	require.NoError(t, obj.close())
+	_, _, err = obj.NewDWARF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestDebugLink(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+
+	name, crc, ok, err := obj.DebugLink()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "readelf-sections.debug", name)
+	require.Equal(t, uint32(2366737317), crc) // needs to be changed if the fixture is regenerated.
+
+	obj, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	_, _, ok, err = obj.DebugLink()
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestIsELFPath(t *testing.T) {
+	ok, err := IsELFPath(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"empty":  {},
+		"short":  []byte("\x7fE"),
+		"script": []byte("#!/bin/sh\necho hello\n"),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+
+		ok, err := IsELFPath(path)
+		require.NoError(t, err, name)
+		require.False(t, ok, name)
+	}
+
+	_, err = IsELFPath(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSections(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+
+	require.True(t, obj.HasSection(".text"))
+	require.True(t, obj.HasSection(".gnu_debuglink"))
+	require.False(t, obj.HasSection(".debug_info"))
+
+	names := obj.SectionNames()
+	require.Contains(t, names, ".text")
+	require.Contains(t, names, ".gopclntab")
+	require.NotContains(t, names, "")
+
+	// The cached lookups return the same sections as the ELF file.
+	ef, err := obj.ELF()
+	require.NoError(t, err)
+	for _, name := range names {
+		require.Same(t, ef.Section(name), obj.section(ef, name), name)
+	}
+	require.Nil(t, obj.section(ef, ".debug_info"))
+}
+
+func TestSymbolizationCapability(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	for name, want := range map[string]Capability{
+		"exe_linux_64":     CapabilityFullDWARF,
+		"readelf-sections": CapabilityGoPCLnTab,
+		"fib":              CapabilitySymtabOnly,
+		"nobuildid-a":      CapabilityDynsymOnly,
+	} {
+		obj, err := objFilePool.Open(filepath.Join("./testdata", name))
+		require.NoError(t, err)
+		require.Equal(t, want, obj.SymbolizationCapability(), name)
+	}
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.NoError(t, obj.close())
+	require.Equal(t, CapabilityNone, obj.SymbolizationCapability())
+	require.Equal(t, "none", CapabilityNone.String())
+}
+
+func TestGoPCLnTab(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+
+	data, addr, err := obj.GoPCLnTab()
+	require.NoError(t, err)
+	require.NotEmpty(t, data)
+
+	ef, err := obj.ELF()
+	require.NoError(t, err)
+	require.Equal(t, ef.Section(".gopclntab").Addr, addr)
+
+	// The table should be usable for symbolization.
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, ef.Section(".text").Addr))
+	require.NoError(t, err)
+	require.NotNil(t, table.LookupFunc("main.main"))
+
+	obj, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	_, _, err = obj.GoPCLnTab()
+	require.ErrorIs(t, err, ErrSectionNotFound)
+}
+
+func TestSymbolForAddr(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+
+	name, start, err := obj.SymbolForAddr(0x4b4ca0)
+	require.NoError(t, err)
+	require.Equal(t, "main.main", name)
+	require.Equal(t, uint64(0x4b4ca0), start)
+
+	name, start, err = obj.SymbolForAddr(0x433920 + 0x100)
+	require.NoError(t, err)
+	require.Equal(t, "runtime.main", name)
+	require.Equal(t, uint64(0x433920), start)
+
+	_, _, err = obj.SymbolForAddr(0x1000)
+	require.ErrorIs(t, err, ErrSymbolNotFound)
+}
+
+func TestSymbolForAddrPLT(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	// Calls printf from libc through the PLT, with both .plt and .plt.sec.
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	name, start, err := obj.SymbolForAddr(0x1050 + 0x4)
+	require.NoError(t, err)
+	require.Equal(t, "printf@plt", name)
+	require.Equal(t, uint64(0x1050), start)
+
+	// The lazy binding stub in .plt, after the reserved header.
+	name, start, err = obj.SymbolForAddr(0x1030)
+	require.NoError(t, err)
+	require.Equal(t, "printf@plt", name)
+	require.Equal(t, uint64(0x1030), start)
+}
+
+func TestGoBuildInfo(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "readelf-sections"))
+	require.NoError(t, err)
+
+	info, err := obj.GoBuildInfo()
+	require.NoError(t, err)
+	require.Equal(t, "go1.18", info.GoVersion)
+	require.Equal(t, "command-line-arguments", info.Path)
+	require.Len(t, info.Deps, 1)
+	require.Equal(t, "github.com/dustin/go-humanize", info.Deps[0].Path)
+	require.Equal(t, "v1.0.0", info.Deps[0].Version)
+
+	obj, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	_, err = obj.GoBuildInfo()
+	require.Error(t, err)
+}
+
+func TestDemangledSymbolForAddr(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	tests := []struct {
+		name string
+		path string
+		addr uint64
+		want Symbol
+	}{
+		{
+			name: "C++",
+			path: "demangle-cpp",
+			addr: 0x1130,
+			want: Symbol{Name: "_ZN5parca5agent3fibEi", Demangled: "parca::agent::fib(int)", Start: 0x1129},
+		},
+		{
+			name: "Rust",
+			path: "demangle-rust",
+			addr: 0x201190,
+			want: Symbol{Name: "_RNvNtCshA6ojhDiTV4_2rs5parca3fib", Demangled: "rs::parca::fib", Start: 0x201190},
+		},
+		{
+			name: "C",
+			path: "demangle-cpp",
+			addr: 0x1164,
+			want: Symbol{Name: "main", Demangled: "main", Start: 0x1164},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := objFilePool.Open(filepath.Join("./testdata", tt.path))
+			require.NoError(t, err)
+
+			got, err := obj.DemangledSymbolForAddr(tt.addr)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// BenchmarkSymbolize resolves many addresses of the same binary, looking up the sections
+// a symbolizer checks for each address, with and without the section map of the object file.
+func BenchmarkSymbolize(b *testing.B) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	b.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "exe_linux_64"))
+	require.NoError(b, err)
+	ef, err := obj.ELF()
+	require.NoError(b, err)
+	addr, _, size, err := obj.TextSection()
+	require.NoError(b, err)
+
+	for _, bb := range []struct {
+		name    string
+		section func(name string) *elf.Section
+	}{
+		{name: "elf", section: ef.Section},
+		{name: "cached", section: func(name string) *elf.Section { return obj.section(ef, name) }},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, name := range []string{".debug_info", ".gopclntab", ".text"} {
+					_ = bb.section(name)
+				}
+				_, _, _ = obj.SymbolForAddr(addr + uint64(i)%size)
+			}
+		})
+	}
+}
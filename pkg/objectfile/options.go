@@ -0,0 +1,114 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"debug/elf"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Option func(p *Pool)
+
+// WithExpiryMultiplier sets the number of profiling cycles an object file is kept in the pool after it is opened.
+// Object files keep their file descriptors open while they are in the pool, so this is a trade-off:
+// a low value causes the same files to be re-opened (and their build IDs re-computed) over and over,
+// while a high value keeps more file descriptors open and can exhaust the open files limit.
+// Values lower than 1 are ignored.
+func WithExpiryMultiplier(n int) Option {
+	return func(p *Pool) {
+		if n < 1 {
+			return
+		}
+		p.expiryMultiplier = n
+	}
+}
+
+// WithMaxDecompressedSize sets the limit of the size in bytes of the compressed object files once decompressed,
+// as they are decompressed to temporary files. Files above the limit fail to open with ErrDecompressedTooLarge.
+// By default, the limit is 4GiB. Values lower than 1 are ignored.
+func WithMaxDecompressedSize(n int64) Option {
+	return func(p *Pool) {
+		if n < 1 {
+			return
+		}
+		p.maxDecompressedSize = n
+	}
+}
+
+// WithDebuginfodURLs sets the debuginfod servers FetchDebugInfo fetches debuginfo files from.
+// By default, the servers in the DEBUGINFOD_URLS environment variable are used.
+func WithDebuginfodURLs(urls []string) Option {
+	return func(p *Pool) {
+		p.debuginfodURLs = urls
+	}
+}
+
+// WithDebuginfodTimeout sets the timeout of a single download from a debuginfod server.
+// Values lower than or equal to 0 are ignored.
+func WithDebuginfodTimeout(timeout time.Duration) Option {
+	return func(p *Pool) {
+		if timeout <= 0 {
+			return
+		}
+		p.debuginfodTimeout = timeout
+	}
+}
+
+// WithVerboseLogging logs every event of the lifecycle of the object files at debug level,
+// e.g. when they are opened, shared, reopened and closed, with their paths and build IDs.
+// It is meant to trace where file descriptors are retained, and is too noisy for production.
+func WithVerboseLogging() Option {
+	return func(p *Pool) {
+		p.verbose = true
+	}
+}
+
+// BuildIDFunc computes the build ID of an object file, e.g. to prefer the Go build ID or to always hash the contents.
+// The build ID is part of the key object files are shared by in the pool.
+type BuildIDFunc func(f *os.File, ef *elf.File) (string, error)
+
+// WithBuildIDFunc sets the strategy the pool computes the build IDs of the files it opens with.
+// By default, the Go or GNU build ID notes are used, falling back to the hash of the .text section.
+// Object files opened with a custom strategy never have SyntheticBuildID set.
+// The build ID of the vdso, which is read from the memory of a process, is always computed with the default strategy.
+func WithBuildIDFunc(fn BuildIDFunc) Option {
+	return func(p *Pool) {
+		p.buildIDFunc = fn
+	}
+}
+
+// WithRetentionPolicy sets the policy that decides whether the object files evicted from the pool are closed,
+// or kept open, e.g. to keep the files of long-running processes open for longer than the expiry of the pool.
+// By default, RetainPinned is used. A nil policy is ignored.
+func WithRetentionPolicy(rp RetentionPolicy) Option {
+	return func(p *Pool) {
+		if rp == nil {
+			return
+		}
+		p.retention = rp
+	}
+}
+
+// WithTracerProvider sets the tracer provider of the spans around the expensive operations of the pool,
+// e.g. opening the files, parsing their ELF headers and computing their build IDs.
+// By default, a no-op tracer is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(p *Pool) {
+		p.tracer = tp.Tracer("objectfile")
+	}
+}
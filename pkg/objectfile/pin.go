@@ -0,0 +1,204 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/go-kit/log/level"
+)
+
+// pins keeps track of the pinned build IDs,
+// and of the object files of pinned build IDs that have been evicted from the pool's cache.
+type pins struct {
+	mtx *sync.Mutex
+	// Number of Pin calls, not yet matched by an Unpin call, by build ID.
+	counts map[string]int
+	// Object files that would have been closed if the retention policy didn't keep them, e.g. as they are pinned.
+	retained map[cacheKey]*ObjectFile
+}
+
+func newPins() *pins {
+	return &pins{
+		mtx:      &sync.Mutex{},
+		counts:   map[string]int{},
+		retained: map[cacheKey]*ObjectFile{},
+	}
+}
+
+// Pin keeps the object files with the given build ID open, even after they expire or are evicted from the pool,
+// e.g. for the main executable of the profiled application, which is expensive to reopen while it is being uploaded.
+// Pins are counted, so every Pin call must be matched by an Unpin call.
+// The build ID doesn't need to be opened yet.
+func (p *Pool) Pin(buildID string) {
+	p.pins.mtx.Lock()
+	defer p.pins.mtx.Unlock()
+
+	p.pins.counts[buildID]++
+	p.metrics.pinned.Set(float64(len(p.pins.counts)))
+}
+
+// Unpin releases a pin taken by Pin.
+// Once the build ID is not pinned anymore, its object files that have been evicted from the pool are closed,
+// unless the retention policy keeps them.
+func (p *Pool) Unpin(buildID string) {
+	p.pins.mtx.Lock()
+	n, ok := p.pins.counts[buildID]
+	if !ok {
+		p.pins.mtx.Unlock()
+		return
+	}
+	if n > 1 {
+		p.pins.counts[buildID] = n - 1
+		p.pins.mtx.Unlock()
+		return
+	}
+	delete(p.pins.counts, buildID)
+	p.metrics.pinned.Set(float64(len(p.pins.counts)))
+
+	var objs []*ObjectFile
+	for k, obj := range p.pins.retained {
+		if k.buildID == buildID && p.retention.ShouldEvict(obj, obj.lastAccess.Load(), 0) {
+			objs = append(objs, obj)
+			delete(p.pins.retained, k)
+		}
+	}
+	p.pins.mtx.Unlock()
+
+	for _, obj := range objs {
+		p.closeObject(obj)
+	}
+}
+
+// Acquire opens the object file at the given path and pins its build ID until the returned closer is closed,
+// so it can't be closed by the pool while it is in use, e.g.:
+//
+//	obj, closer, err := p.Acquire(path)
+//	if err != nil {
+//		return err
+//	}
+//	defer closer.Close()
+//
+// Closing the returned closer more than once is a no-op.
+func (p *Pool) Acquire(path string) (*ObjectFile, io.Closer, error) {
+	obj, err := p.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Pin(obj.BuildID)
+	// The object file could have been evicted and closed before it was pinned.
+	if _, err := obj.ELF(); err != nil {
+		p.Unpin(obj.BuildID)
+		return nil, nil, err
+	}
+	return obj, &unpinCloser{p: p, buildID: obj.BuildID}, nil
+}
+
+// unpinCloser releases a pin taken by Acquire.
+type unpinCloser struct {
+	p       *Pool
+	buildID string
+	once    sync.Once
+}
+
+func (c *unpinCloser) Close() error {
+	c.once.Do(func() {
+		c.p.Unpin(c.buildID)
+	})
+	return nil
+}
+
+// retain keeps the evicted object file if the retention policy says so, e.g. its build ID is pinned,
+// and reports whether it did so.
+func (p *Pool) retain(k cacheKey, obj *ObjectFile) bool {
+	p.pins.mtx.Lock()
+	defer p.pins.mtx.Unlock()
+
+	if p.retention.ShouldEvict(obj, obj.lastAccess.Load(), p.pins.counts[k.buildID]) {
+		return false
+	}
+	p.pins.retained[k] = obj
+	return true
+}
+
+// sweep closes the retained object files the retention policy doesn't keep anymore,
+// e.g. the ones that haven't been accessed for a while.
+func (p *Pool) sweep() {
+	p.pins.mtx.Lock()
+	var objs []*ObjectFile
+	for k, obj := range p.pins.retained {
+		if p.retention.ShouldEvict(obj, obj.lastAccess.Load(), p.pins.counts[k.buildID]) {
+			objs = append(objs, obj)
+			delete(p.pins.retained, k)
+		}
+	}
+	p.pins.mtx.Unlock()
+
+	for _, obj := range objs {
+		p.closeObject(obj)
+	}
+}
+
+// restore returns the retained object file for the given key, and moves it back to the pool's cache.
+func (p *Pool) restore(k cacheKey) (*ObjectFile, bool) {
+	p.pins.mtx.Lock()
+	obj, ok := p.pins.retained[k]
+	if ok {
+		delete(p.pins.retained, k)
+	}
+	p.pins.mtx.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	p.addToCache(k, obj)
+	return obj, true
+}
+
+// drop closes the retained object file for the given key, if any, even if its build ID is still pinned.
+func (p *Pool) drop(k cacheKey) {
+	p.pins.mtx.Lock()
+	obj, ok := p.pins.retained[k]
+	delete(p.pins.retained, k)
+	p.pins.mtx.Unlock()
+
+	if ok {
+		p.closeObject(obj)
+	}
+}
+
+// closeRetained closes all the retained object files, regardless of their pins.
+func (p *Pool) closeRetained() error {
+	p.pins.mtx.Lock()
+	retained := p.pins.retained
+	p.pins.retained = map[cacheKey]*ObjectFile{}
+	p.pins.mtx.Unlock()
+
+	var errs error
+	for _, obj := range retained {
+		if err := obj.close(); err != nil && !errors.Is(err, ErrAlreadyClosed) {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+func (p *Pool) closeObject(obj *ObjectFile) {
+	if err := obj.close(); err != nil {
+		level.Debug(p.logger).Log("msg", "failed to close retained object file", "path", obj.Path, "err", err)
+	}
+}
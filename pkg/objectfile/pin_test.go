@@ -0,0 +1,122 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/parca-dev/parca-agent/pkg/cache"
+)
+
+func TestPoolPin(t *testing.T) {
+	// A pool of a single entry, so opening another file evicts the previous one.
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	pinned, err := objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	objFilePool.Pin(pinned.BuildID)
+	objFilePool.Pin(pinned.BuildID)
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+
+	other, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	// Evicted, but still open.
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.evicted.WithLabelValues(string(cache.EvictionReasonSize))), 0)
+	_, err = pinned.ELF()
+	require.NoError(t, err)
+	reopened, err := objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	require.Same(t, pinned, reopened)
+
+	// Not pinned, so closed once evicted.
+	_, err = other.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+
+	_, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	// Pins are counted.
+	objFilePool.Unpin(pinned.BuildID)
+	_, err = pinned.ELF()
+	require.NoError(t, err)
+
+	objFilePool.Unpin(pinned.BuildID)
+	require.InDelta(t, 0, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+	_, err = pinned.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+
+	// Unpinning a build ID that is not pinned is a no-op.
+	objFilePool.Unpin(pinned.BuildID)
+}
+
+func TestPoolCloseClosesPinned(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	objFilePool.Pin(obj.BuildID)
+
+	require.NoError(t, objFilePool.Close())
+	_, err = obj.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestPoolAcquire(t *testing.T) {
+	// A pool of a single entry, so opening another file evicts the previous one.
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, closer, err := objFilePool.Acquire(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+
+	_, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	// Evicted, but still open while it is acquired.
+	_, err = obj.ELF()
+	require.NoError(t, err)
+
+	require.NoError(t, closer.Close())
+	require.InDelta(t, 0, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+	_, err = obj.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+
+	// Closing twice doesn't release the pins of others.
+	other, otherCloser, err := objFilePool.Acquire(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	objFilePool.Pin(other.BuildID)
+	require.NoError(t, otherCloser.Close())
+	require.NoError(t, otherCloser.Close())
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+	objFilePool.Unpin(other.BuildID)
+
+	_, _, err = objFilePool.Acquire(filepath.Join("./testdata", "missing"))
+	require.Error(t, err)
+	require.InDelta(t, 0, testutil.ToFloat64(objFilePool.metrics.pinned), 0)
+}
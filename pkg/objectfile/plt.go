@@ -0,0 +1,101 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+)
+
+const pltSuffix = "@plt"
+
+// pltLayout returns the size of the reserved header of the .plt section and the size of its entries.
+func pltLayout(machine elf.Machine) (header, entry uint64, ok bool) { //nolint:nonamedreturns
+	switch machine { //nolint:exhaustive
+	case elf.EM_X86_64:
+		return 16, 16, true
+	case elf.EM_AARCH64:
+		return 32, 16, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// pltSymbols returns synthetic function symbols for the PLT entries of the ELF file,
+// named after the symbol they jump to with a @plt suffix, e.g. printf@plt.
+// The n-th entry of the PLT (after its header) and of .plt.sec, if any, jumps to the symbol of the n-th .rela.plt relocation.
+// Only 64-bit x86 and arm64 files are supported, nil is returned otherwise.
+func pltSymbols(ef *elf.File) ([]elf.Symbol, error) {
+	header, entry, ok := pltLayout(ef.Machine)
+	if !ok || ef.Class != elf.ELFCLASS64 {
+		return nil, nil
+	}
+	rela := ef.Section(".rela.plt")
+	if rela == nil || rela.Type != elf.SHT_RELA {
+		return nil, nil
+	}
+
+	data, err := rela.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .rela.plt section: %w", err)
+	}
+	dynsyms, err := ef.DynamicSymbols()
+	if err != nil {
+		if errors.Is(err, elf.ErrNoSymbols) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read dynamic symbols: %w", err)
+	}
+
+	var names []string
+	for len(data) >= 24 {
+		// Elf64_Rela: r_offset, r_info and r_addend.
+		info := ef.ByteOrder.Uint64(data[8:16])
+		data = data[24:]
+
+		// DynamicSymbols skips the null symbol at index 0.
+		idx := elf.R_SYM64(info)
+		if idx == 0 || int(idx) > len(dynsyms) {
+			names = append(names, "")
+			continue
+		}
+		names = append(names, dynsyms[idx-1].Name)
+	}
+
+	var syms []elf.Symbol
+	add := func(sec *elf.Section, start uint64) {
+		for i, name := range names {
+			addr := start + uint64(i)*entry
+			if name == "" || addr+entry > sec.Addr+sec.Size {
+				continue
+			}
+			syms = append(syms, elf.Symbol{
+				Name:  name + pltSuffix,
+				Info:  elf.ST_INFO(elf.STB_LOCAL, elf.STT_FUNC),
+				Value: addr,
+				Size:  entry,
+			})
+		}
+	}
+	if sec := ef.Section(".plt"); sec != nil {
+		add(sec, sec.Addr+header)
+	}
+	// With Intel CET enabled, the calls go through .plt.sec, which has no header.
+	if sec := ef.Section(".plt.sec"); sec != nil {
+		add(sec, sec.Addr)
+	}
+	return syms, nil
+}
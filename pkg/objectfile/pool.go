@@ -15,19 +15,31 @@
 package objectfile
 
 import (
+	"context"
 	"debug/elf"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/parca-dev/parca-agent/pkg/buildid"
 	"github.com/parca-dev/parca-agent/pkg/cache"
@@ -41,17 +53,26 @@ type Cache[K comparable, V any] interface {
 	Purge()
 }
 
+// evictingCache is a Cache that can evict its entries on demand, e.g. when running out of file descriptors.
+type evictingCache[K comparable, V any] interface {
+	Cache[K, V]
+	Evict()
+}
+
 const (
 	lvSuccess = "success"
 	lvError   = "error"
 	lvShared  = "shared"
 
-	lvNotFound    = "not_found"
-	lvNotELF      = "not_elf"
-	lvOpenUnknown = "open_unknown"
-	lvBuildID     = "build_id"
-	lvRewind      = "rewind"
-	lvStat        = "stat"
+	lvNotFound         = "not_found"
+	lvNotELF           = "not_elf"
+	lvOpenUnknown      = "open_unknown"
+	lvBuildID          = "build_id"
+	lvRewind           = "rewind"
+	lvStat             = "stat"
+	lvDecompress       = "decompress"
+	lvTooManyOpenFiles = "too_many_open_files"
+	lvTruncated        = "truncated"
 )
 
 type metrics struct {
@@ -60,7 +81,10 @@ type metrics struct {
 	open             prometheus.Gauge
 	closeAttempts    prometheus.Counter
 	closed           *prometheus.CounterVec
+	evicted          *prometheus.CounterVec
 	keptOpenDuration prometheus.Histogram
+	pinned           prometheus.Gauge
+	fdUsage          prometheus.Gauge
 }
 
 func newMetrics(reg prometheus.Registerer) *metrics {
@@ -85,11 +109,23 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 			Name: "parca_agent_objectfile_closed_total",
 			Help: "Total number of object file close operations.",
 		}, []string{"result"}),
+		evicted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "parca_agent_objectfile_evicted_total",
+			Help: "Total number of object files evicted from the pool by reason.",
+		}, []string{"reason"}),
 		keptOpenDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
 			Name:                        "parca_agent_objectfile_kept_open_duration_seconds",
 			Help:                        "Duration of object files kept open.",
 			NativeHistogramBucketFactor: 1.1,
 		}),
+		pinned: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "parca_agent_objectfile_pinned",
+			Help: "Total number of pinned build IDs.",
+		}),
+		fdUsage: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "parca_agent_objectfile_fd_usage_ratio",
+			Help: "Ratio of the open file descriptors of the process to its limit, as of the last object file open.",
+		}),
 	}
 	m.opened.WithLabelValues(lvSuccess)
 	m.opened.WithLabelValues(lvError)
@@ -100,8 +136,19 @@ func newMetrics(reg prometheus.Registerer) *metrics {
 	m.openErrors.WithLabelValues(lvBuildID)
 	m.openErrors.WithLabelValues(lvRewind)
 	m.openErrors.WithLabelValues(lvStat)
+	m.openErrors.WithLabelValues(lvDecompress)
+	m.openErrors.WithLabelValues(lvTooManyOpenFiles)
+	m.openErrors.WithLabelValues(lvTruncated)
 	m.closed.WithLabelValues(lvSuccess)
 	m.closed.WithLabelValues(lvError)
+	for _, reason := range []cache.EvictionReason{
+		cache.EvictionReasonSize,
+		cache.EvictionReasonExpired,
+		cache.EvictionReasonRemoved,
+		cache.EvictionReasonPurged,
+	} {
+		m.evicted.WithLabelValues(string(reason))
+	}
 	return m
 }
 
@@ -114,6 +161,10 @@ type cacheKey struct {
 	// - (for singleton objects) /usr/lib/modules/5.4.0-65-generic/vdso/vdso64.so
 	path    string
 	buildID string
+	// Binaries without a build ID note could end up with an empty or a
+	// content-derived build ID, so path, size and modtime are used to make sure
+	// distinct files never share an entry.
+	size    int64
 	modtime time.Time
 }
 
@@ -123,79 +174,279 @@ type Pool struct {
 
 	// There could be multiple object files mapped to different processes.
 	keyCache Cache[string, cacheKey]
-	objCache Cache[cacheKey, *ObjectFile]
+	objCache evictingCache[cacheKey, *ObjectFile]
+	// Makes looking up and adding an object file to the cache atomic, see getOrAdd.
+	cacheMtx *sync.Mutex
+	sfg      *singleflight.Group
+	pins     *pins
+	// Decides whether the evicted object files are closed or retained.
+	retention RetentionPolicy
+
+	// Number of open object files by build ID, including the evicted ones that are retained.
+	buildIDsMtx *sync.Mutex
+	buildIDs    map[string]int
+
+	// Counters of the cache, kept along with the metrics, see Stat.
+	entries *atomic.Int64
+	size    *atomic.Int64
+	hits    *atomic.Uint64
+	misses  *atomic.Uint64
+	reopens *atomic.Uint64
+
+	expiryMultiplier    int
+	maxDecompressedSize int64
+	verbose             bool
+	buildIDFunc         BuildIDFunc
+	tracer              trace.Tracer
+	// Estimates the file descriptor usage of the process.
+	fds *fdCounter
+
+	debuginfodURLs    []string
+	debuginfodTimeout time.Duration
+	debuginfodDir     string
+	debuginfodMisses  Cache[string, struct{}]
 }
 
-const keepAliveProfileCycle = 18
+// defaultExpiryMultiplier is the default number of profiling cycles an object file is kept in the pool.
+// See WithExpiryMultiplier.
+const defaultExpiryMultiplier = 18
 
-func NewPool(logger log.Logger, reg prometheus.Registerer, evictionPolicy string, poolSize int, profilingDuration time.Duration) *Pool {
+func NewPool(logger log.Logger, reg prometheus.Registerer, evictionPolicy string, poolSize int, profilingDuration time.Duration, opts ...Option) *Pool {
 	p := &Pool{
-		logger:  logger,
-		metrics: newMetrics(reg),
-		// NOTICE: The behavior is now different than the previous implementation.
-		// - The previous implementation was using a ExpireAfterAccess strategy, now it is behaves like ExpireAfterWrite strategy.
-		// - This could be better it just needs to be noted.
-		keyCache: cache.NewLFUCacheWithTTL[string, cacheKey](
-			prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile_key"}, reg),
-			poolSize,
-			keepAliveProfileCycle*profilingDuration,
-		),
+		logger:   logger,
+		metrics:  newMetrics(reg),
+		cacheMtx: &sync.Mutex{},
+		sfg:      &singleflight.Group{},
+		pins:     newPins(),
+
+		retention: RetainPinned,
+
+		buildIDsMtx: &sync.Mutex{},
+		buildIDs:    map[string]int{},
+
+		entries: atomic.NewInt64(0),
+		size:    atomic.NewInt64(0),
+		hits:    atomic.NewUint64(0),
+		misses:  atomic.NewUint64(0),
+		reopens: atomic.NewUint64(0),
+
+		expiryMultiplier:    defaultExpiryMultiplier,
+		maxDecompressedSize: defaultMaxDecompressedSize,
+		tracer:              noop.NewTracerProvider().Tracer(""),
+		fds:                 newFDCounter(processFDs),
+
+		debuginfodURLs:    debuginfodURLs(),
+		debuginfodTimeout: defaultDebuginfodTimeout,
+		debuginfodDir:     filepath.Join(os.TempDir(), "parca-agent", "debuginfod"),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
+	ttl := time.Duration(p.expiryMultiplier) * profilingDuration
+	// NOTICE: The behavior is now different than the previous implementation.
+	// - The previous implementation was using a ExpireAfterAccess strategy, now it is behaves like ExpireAfterWrite strategy.
+	// - This could be better it just needs to be noted.
+	p.keyCache = cache.NewLFUCacheWithTTL[string, cacheKey](
+		prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile_key"}, reg),
+		poolSize,
+		ttl,
+	)
+
+	p.debuginfodMisses = cache.NewLRUCacheWithTTL[string, struct{}](
+		prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile_debuginfod_miss"}, reg),
+		poolSize,
+		debuginfodNegativeTTL,
+	)
+
 	switch evictionPolicy {
 	case "lfu":
-		p.objCache = cache.NewLFUCacheWithEvictionTTL[cacheKey, *ObjectFile](
+		p.objCache = cache.NewLFUCacheWithEvictionReasonTTL[cacheKey, *ObjectFile](
 			prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile"}, reg),
 			poolSize,
-			keepAliveProfileCycle*profilingDuration,
+			ttl,
 			p.onEvicted,
 		)
 	case "lru":
-		p.objCache = cache.NewLRUCacheWithEvictionTTL[cacheKey, *ObjectFile](
+		p.objCache = cache.NewLRUCacheWithEvictionReasonTTL[cacheKey, *ObjectFile](
 			prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile"}, reg),
 			poolSize,
-			keepAliveProfileCycle*profilingDuration,
+			ttl,
 			p.onEvicted,
 		)
 	default:
-		p.objCache = cache.NewLRUCacheWithEvictionTTL[cacheKey, *ObjectFile](
+		p.objCache = cache.NewLRUCacheWithEvictionReasonTTL[cacheKey, *ObjectFile](
 			prometheus.WrapRegistererWith(prometheus.Labels{"cache": "objectfile"}, reg),
 			poolSize,
-			keepAliveProfileCycle*profilingDuration,
+			ttl,
 			p.onEvicted,
 		)
 	}
 	return p
 }
 
-func (p *Pool) onEvicted(k cacheKey, obj *ObjectFile) {
-	level.Debug(p.logger).Log("msg", "evicting object file", "key", fmt.Sprintf("%+v", k))
+func (p *Pool) onEvicted(k cacheKey, obj *ObjectFile, reason cache.EvictionReason) {
+	p.metrics.evicted.WithLabelValues(string(reason)).Inc()
+	p.entries.Dec()
+	p.size.Sub(obj.Size)
+	if p.retain(k, obj) {
+		if reason == cache.EvictionReasonSize {
+			// Retained object files are kept open outside of the pool's size limit.
+			level.Warn(p.logger).Log("msg", "object file evicted while still in use, consider increasing the pool size", "path", obj.Path, "buildid", k.buildID)
+			return
+		}
+		level.Debug(p.logger).Log("msg", "retaining evicted object file", "key", fmt.Sprintf("%+v", k), "reason", reason)
+		return
+	}
+	level.Debug(p.logger).Log("msg", "evicting object file", "key", fmt.Sprintf("%+v", k), "reason", reason)
+	if obj.IsClosed() {
+		return
+	}
 	if err := obj.close(); err != nil {
 		level.Debug(p.logger).Log("msg", "failed to close object file when evicted", "err", err)
 	}
 }
 
+// logEvent logs an event of the lifecycle of the object file, if verbose logging is enabled.
+func (p *Pool) logEvent(msg string, obj *ObjectFile) {
+	if !p.verbose {
+		return
+	}
+	level.Debug(p.logger).Log("msg", msg, "path", obj.Path, "buildid", obj.BuildID, "opened_at", obj.openedAt)
+}
+
 func (p *Pool) get(key cacheKey) (*ObjectFile, error) {
+	p.cacheMtx.Lock()
+	defer p.cacheMtx.Unlock()
+
+	return p.lookup(key)
+}
+
+// lookup returns the cached or the retained object file for the given key.
+// The caller must hold cacheMtx.
+func (p *Pool) lookup(key cacheKey) (*ObjectFile, error) {
 	if obj, ok := p.objCache.Get(key); ok {
+		obj.lastAccess.Store(time.Now())
+		p.hits.Inc()
 		p.metrics.opened.WithLabelValues(lvShared).Inc()
+		p.logEvent("object file shared", obj)
+		return obj, nil
+	}
+	// The object file could have been evicted while it is retained, e.g. its build ID is pinned.
+	if obj, ok := p.restore(key); ok {
+		obj.lastAccess.Store(time.Now())
+		p.hits.Inc()
+		p.metrics.opened.WithLabelValues(lvShared).Inc()
+		p.logEvent("retained object file restored", obj)
 		return obj, nil
 	}
 	return nil, fmt.Errorf("no reference found for %s", key.path)
 }
 
+// getOrAdd returns the object file for the given key if there is one already, otherwise it adds the given object file to the cache.
+// Concurrent opens of the same file through different paths, e.g. the roots of different processes, end up with the same key,
+// so only one of them must be cached. It reports whether the given object file has been added.
+func (p *Pool) getOrAdd(key cacheKey, obj *ObjectFile) (*ObjectFile, bool) {
+	p.cacheMtx.Lock()
+	defer p.cacheMtx.Unlock()
+
+	if cached, err := p.lookup(key); err == nil {
+		return cached, false
+	}
+	p.addToCache(key, obj)
+	return obj, true
+}
+
 // Open opens the specified executable or library file from the given path.
 // And creates a new ObjectFile reference.
 // The returned reference should be released after use.
 // The file will be closed when the reference is released.
-func (p *Pool) Open(path string) (*ObjectFile, error) {
+func (p *Pool) Open(path string) (obj *ObjectFile, err error) { //nolint:nonamedreturns
+	ctx, span := p.tracer.Start(context.Background(), "objectfile.Pool.Open", trace.WithAttributes(attribute.String("path", path)))
+	defer func() {
+		endSpan(span, obj, err)
+	}()
+
 	if key, ok := p.keyCache.Get(path); ok {
-		if obj, err := p.get(key); err == nil {
-			return obj, nil
+		obj, err := p.get(key)
+		if err == nil {
+			stat, err := os.Stat(path)
+			if err != nil || obj.validate(stat) == nil {
+				return obj, nil
+			}
+			// The file has been replaced in place (e.g. a self-updating binary or a re-used path
+			// in a short-lived container), so the cached file descriptor is stale.
+			level.Debug(p.logger).Log("msg", "object file has changed on disk, reopening", "path", path)
+			return p.reopen(ctx, key, path)
 		}
 		// There is liveness difference between two caches, so we need to remove the key from the keyCache,
 		// if it is NOT found in the objCache.
 		p.keyCache.Remove(path)
 	}
+	return p.openShared(ctx, path)
+}
+
+// reopen opens the file at the given path again, after it has changed on disk since it was opened with the given key.
+func (p *Pool) reopen(ctx context.Context, key cacheKey, path string) (obj *ObjectFile, err error) { //nolint:nonamedreturns
+	ctx, span := p.tracer.Start(ctx, "objectfile.Pool.reopen", trace.WithAttributes(attribute.String("path", path)))
+	defer func() {
+		endSpan(span, obj, err)
+	}()
+
+	p.reopens.Inc()
+	p.objCache.Remove(key)
+	// Pins don't keep stale files open.
+	p.drop(key)
+	p.keyCache.Remove(path)
+	return p.openShared(ctx, path)
+}
+
+func (p *Pool) openShared(ctx context.Context, path string) (*ObjectFile, error) {
+	// Concurrent opens of the same file share a single open, instead of opening it and computing its build ID multiple times.
+	val, err, _ := p.sfg.Do(path, func() (interface{}, error) {
+		return p.open(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*ObjectFile), nil //nolint:forcetypeassert
+}
+
+// OpenInProcess opens the executable or library file at the given path in the mount namespace of the process with the given PID,
+// e.g. a binary of a container, which isn't visible at the same path from the host, through /proc/<pid>/root.
+// If the file can't be found there, it falls back to the path as is, but only if the process shares the root of the host,
+// as the same path could be a different file outside of a container.
+// Files of the same image in different containers are shared, as their paths without the /proc/<pid>/root prefix are the same.
+// The returned reference should be released after use.
+func (p *Pool) OpenInProcess(pid int, path string) (*ObjectFile, error) {
+	obj, err := p.Open(filepath.Join("/proc", strconv.Itoa(pid), "root", path))
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || !sharesRoot(pid) {
+		return obj, err
+	}
+	return p.Open(path)
+}
+
+// sharesRoot returns whether the process with the given PID has the same root directory as the host,
+// i.e. its files are at the same paths. It returns false if the process doesn't exist.
+func sharesRoot(pid int) bool {
+	root, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid), "root"))
+	if err != nil {
+		return false
+	}
+	host, err := os.Stat("/")
+	if err != nil {
+		return false
+	}
+	return os.SameFile(root, host)
+}
+
+func (p *Pool) open(ctx context.Context, path string) (*ObjectFile, error) {
+	p.sweep()
+	if err := p.ensureFDs(); err != nil {
+		p.metrics.opened.WithLabelValues(lvError).Inc()
+		p.metrics.openErrors.WithLabelValues(lvTooManyOpenFiles).Inc()
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
 
 	f, err := os.Open(path)
 	if err != nil {
@@ -203,9 +454,17 @@ func (p *Pool) Open(path string) (*ObjectFile, error) {
 		if os.IsNotExist(err) || errors.Is(err, fs.ErrNotExist) {
 			p.metrics.openErrors.WithLabelValues(lvNotFound).Inc()
 		}
+		if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+			p.metrics.openErrors.WithLabelValues(lvTooManyOpenFiles).Inc()
+			err = errors.Join(ErrTooManyOpenFiles, err)
+		}
 		return nil, fmt.Errorf("error opening %s: %w", path, err)
 	}
 
+	// The fast path computes the default build ID, it would never match the keys of a custom strategy.
+	if p.buildIDFunc != nil {
+		return p.newFile(ctx, f, "")
+	}
 	key, err := cacheKeyFromFile(f)
 	if err == nil {
 		if obj, err := p.get(key); err == nil {
@@ -215,10 +474,72 @@ func (p *Pool) Open(path string) (*ObjectFile, error) {
 			// - if a singleton object was opened by another process and requested again.
 			// - if a debuginfo extracted from the same source objectfile (if happens it's a race condition).
 			p.keyCache.Add(path, key)
+			// The shared object file has its own file descriptor.
+			if err := f.Close(); err != nil {
+				level.Debug(p.logger).Log("msg", "failed to close object file", "path", path, "err", err)
+			}
 			return obj, nil
 		}
 	}
-	return p.NewFile(f)
+	return p.newFile(ctx, f, "")
+}
+
+// Preload opens the object files at the given paths, so they are already cached
+// when they are first requested, e.g. for binaries that are known to be profiled at startup.
+// It opens all the paths and returns the joined errors of the ones that failed to open.
+func (p *Pool) Preload(paths []string) error {
+	var errs error
+	for _, path := range paths {
+		if _, err := p.Open(path); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to preload %s: %w", path, err))
+		}
+	}
+	return errs
+}
+
+// OpenMany opens the object files at the given paths concurrently, with at most concurrency opens at a time,
+// e.g. for the binaries discovered during a profiling round, without exhausting the file descriptors.
+// It returns the object files that could be opened by path, and the joined errors of the ones that failed to open.
+// If the context is canceled, it stops opening files, and returns only the context error.
+// The object files opened so far are owned by the pool, as with Open, so they are closed when evicted.
+func (p *Pool) OpenMany(ctx context.Context, paths []string, concurrency int) (map[string]*ObjectFile, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	tokens := semaphore.NewWeighted(int64(concurrency))
+
+	var (
+		wg   sync.WaitGroup
+		mtx  sync.Mutex
+		objs = make(map[string]*ObjectFile, len(paths))
+		errs error
+	)
+	for _, path := range paths {
+		if err := tokens.Acquire(ctx, 1); err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer tokens.Release(1)
+
+			obj, err := p.Open(path)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("failed to open %s: %w", path, err))
+				return
+			}
+			objs[path] = obj
+		}(path)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return objs, errs
 }
 
 //nolint:unused
@@ -232,12 +553,27 @@ var (
 // NewFile creates a new ObjectFile reference from an existing file.
 // The returned reference should be released after use.
 // The file will be closed when the reference is released.
-func (p *Pool) NewFile(f *os.File) (_ *ObjectFile, err error) { //nolint:nonamedreturns
+func (p *Pool) NewFile(f *os.File) (*ObjectFile, error) {
+	return p.NewFileWithBuildID(f, "")
+}
+
+// NewFileWithBuildID creates a new ObjectFile reference from an existing file,
+// trusting the given build ID instead of computing it from the file.
+// This is useful when the build ID is already known (e.g. reported with the mapping).
+// If the given build ID is empty, it is computed from the file.
+// The returned reference should be released after use.
+// The file will be closed when the reference is released.
+func (p *Pool) NewFileWithBuildID(f *os.File, buildID string) (*ObjectFile, error) {
+	return p.newFile(context.Background(), f, buildID)
+}
+
+func (p *Pool) newFile(ctx context.Context, f *os.File, buildID string) (obj *ObjectFile, err error) { //nolint:nonamedreturns
+	ctx, span := p.tracer.Start(ctx, "objectfile.Pool.NewFile", trace.WithAttributes(attribute.String("path", f.Name())))
 	defer func() {
 		if err != nil {
 			p.metrics.opened.WithLabelValues(lvError).Inc()
-			return
 		}
+		endSpan(span, obj, err)
 	}()
 
 	closer := func(err error) error {
@@ -248,13 +584,50 @@ func (p *Pool) NewFile(f *os.File) (_ *ObjectFile, err error) { //nolint:nonamed
 	}
 
 	path := f.Name()
+	// The stats of the file on disk are used to identify it, even if it's decompressed.
+	stat, err := f.Stat()
+	if err != nil {
+		p.metrics.openErrors.WithLabelValues(lvStat).Inc()
+		return nil, closer(fmt.Errorf("failed to get stats of the file: %w", err))
+	}
+	fileSize := stat.Size()
+
+	// Binaries retrieved from artifact stores could be compressed on disk.
+	compressed, err := isGzip(f)
+	if err != nil {
+		p.metrics.openErrors.WithLabelValues(lvOpenUnknown).Inc()
+		return nil, closer(fmt.Errorf("error opening %s: %w", path, err))
+	}
+	if compressed {
+		df, n, err := gunzip(f, stat.Size(), p.maxDecompressedSize)
+		if err != nil {
+			p.metrics.openErrors.WithLabelValues(lvDecompress).Inc()
+			return nil, closer(fmt.Errorf("failed to decompress %s: %w", path, err))
+		}
+		if err := closer(nil); err != nil {
+			level.Debug(p.logger).Log("msg", "failed to close compressed object file", "path", path, "err", err)
+		}
+		// From now on, the object file reads from the decompressed file.
+		f, fileSize = df, n
+	}
+
 	// > Clients of ReadAt can execute parallel ReadAt calls on the same input source.
+	_, elfSpan := p.tracer.Start(ctx, "elf.NewFile")
 	ef, err := elfNewFile(f)
+	if err == nil {
+		err = checkTruncated(ef, fileSize)
+	} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errors.Join(ErrTruncatedELF, err)
+	}
+	endSpan(elfSpan, nil, err)
 	if err != nil {
 		var elfErr *elf.FormatError
-		if errors.As(err, &elfErr) {
+		switch {
+		case errors.Is(err, ErrTruncatedELF):
+			p.metrics.openErrors.WithLabelValues(lvTruncated).Inc()
+		case errors.As(err, &elfErr):
 			p.metrics.openErrors.WithLabelValues(lvNotELF).Inc()
-		} else {
+		default:
 			p.metrics.openErrors.WithLabelValues(lvOpenUnknown).Inc()
 		}
 		return nil, closer(fmt.Errorf("error opening %s: %w", path, err))
@@ -263,66 +636,163 @@ func (p *Pool) NewFile(f *os.File) (_ *ObjectFile, err error) { //nolint:nonamed
 		return nil, closer(errors.New("ELF does not have any sections"))
 	}
 
-	buildID, err := buildid.FromELF(ef)
-	if err != nil {
-		p.metrics.openErrors.WithLabelValues(lvBuildID).Inc()
-		return nil, closer(fmt.Errorf("failed to get build ID from ELF for %s: %w", path, err))
+	var synthetic bool
+	if buildID == "" {
+		_, buildIDSpan := p.tracer.Start(ctx, "buildid.BuildID")
+		if p.buildIDFunc != nil {
+			buildID, err = p.buildIDFunc(f, ef)
+		} else {
+			buildID, synthetic, err = buildIDFromELF(ef)
+		}
+		if err == nil && buildID == "" {
+			err = errors.New("empty build ID")
+		}
+		buildIDSpan.SetAttributes(attribute.String("buildid", buildID), attribute.Bool("synthetic", synthetic))
+		endSpan(buildIDSpan, nil, err)
+		if err != nil {
+			p.metrics.openErrors.WithLabelValues(lvBuildID).Inc()
+			return nil, closer(fmt.Errorf("failed to get build ID from ELF for %s: %w", path, err))
+		}
 	}
 	if rErr := rewind(f); rErr != nil {
 		p.metrics.openErrors.WithLabelValues(lvRewind).Inc()
 		return nil, closer(rErr)
 	}
 
-	stat, err := f.Stat()
-	if err != nil {
-		p.metrics.openErrors.WithLabelValues(lvStat).Inc()
-		return nil, fmt.Errorf("failed to get stats of the file: %w", err)
-	}
-
+	// Files reached through different links share an entry.
+	resolved := realPath(path)
 	key := cacheKey{
-		path:    removeProcPrefix(path),
+		path:    removeProcPrefix(resolved),
 		buildID: buildID,
+		size:    stat.Size(),
 		modtime: stat.ModTime(),
 	}
-	if val, ok := p.objCache.Get(key); ok {
+	if val, err := p.get(key); err == nil {
 		// A file for this buildID is already in the cache, so close the file we just opened.
 		// The existing file could be already closed, because we are done uploading it.
 		// It's the callers responsibility to making sure the file is still open.
 		if err := closer(nil); err != nil {
 			return nil, err
 		}
-		p.metrics.opened.WithLabelValues(lvShared).Inc()
 		return val, nil
 	}
 
-	obj := &ObjectFile{
+	obj = &ObjectFile{
 		p: p,
 
-		BuildID: buildID,
-		Path:    path,
-
-		file:     f,
-		openedAt: time.Now(),
-		Size:     stat.Size(),
-		Modtime:  stat.ModTime(),
-		closed:   atomic.NewBool(false),
-		elf:      ef,
+		BuildID:          buildID,
+		SyntheticBuildID: synthetic,
+		Path:             path,
+		RealPath:         resolved,
+
+		file:       f,
+		openedAt:   time.Now(),
+		lastAccess: atomic.NewTime(time.Now()),
+		Size:       stat.Size(),
+		Modtime:    stat.ModTime(),
+		fileSize:   fileSize,
+		closed:     atomic.NewBool(false),
+		elf:        ef,
 	}
-	p.metrics.opened.WithLabelValues(lvSuccess).Inc()
 	p.metrics.open.Inc()
+	p.trackOpen(obj)
 
 	key = cacheKeyFromObject(obj)
 	p.keyCache.Add(path, key)
-	p.objCache.Add(key, obj)
+	if cached, added := p.getOrAdd(key, obj); !added {
+		// The same file has been opened concurrently, e.g. through the root of another process.
+		if err := obj.close(); err != nil {
+			level.Debug(p.logger).Log("msg", "failed to close object file", "path", path, "err", err)
+		}
+		return cached, nil
+	}
+	p.misses.Inc()
+	p.metrics.opened.WithLabelValues(lvSuccess).Inc()
+	p.logEvent("object file opened", obj)
 	return obj, nil
 }
 
+func (p *Pool) addToCache(key cacheKey, obj *ObjectFile) {
+	p.entries.Inc()
+	p.size.Add(obj.Size)
+	p.objCache.Add(key, obj)
+}
+
+// PoolStat is a snapshot of the state of the pool.
+type PoolStat struct {
+	// Entries is the number of object files in the pool's cache,
+	// not including the evicted ones that are retained.
+	Entries int64
+	// Size is the summed size of the files of the cached object files.
+	Size int64
+	// Hits and Misses are the number of opens served by an already open object file
+	// and the ones that opened a new one, since the pool is created.
+	Hits   uint64
+	Misses uint64
+	// HitRatio is Hits over all the opens, or 0 if nothing has been opened yet.
+	HitRatio float64
+	// Reopens is the number of object files opened again, because they have changed on disk.
+	Reopens uint64
+}
+
+// Stat returns a snapshot of the state of the pool, e.g. for diagnostics.
+// It only reads counters, so it is cheap to call.
+func (p *Pool) Stat() PoolStat {
+	stat := PoolStat{
+		Entries: p.entries.Load(),
+		Size:    p.size.Load(),
+		Hits:    p.hits.Load(),
+		Misses:  p.misses.Load(),
+		Reopens: p.reopens.Load(),
+	}
+	if total := stat.Hits + stat.Misses; total > 0 {
+		stat.HitRatio = float64(stat.Hits) / float64(total)
+	}
+	return stat
+}
+
+// Keys returns the build IDs of the object files the pool currently holds open, sorted,
+// including the ones that are evicted but retained because their build ID is pinned.
+func (p *Pool) Keys() []string {
+	p.buildIDsMtx.Lock()
+	defer p.buildIDsMtx.Unlock()
+
+	keys := make([]string, 0, len(p.buildIDs))
+	for buildID := range p.buildIDs {
+		keys = append(keys, buildID)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (p *Pool) trackOpen(obj *ObjectFile) {
+	p.buildIDsMtx.Lock()
+	defer p.buildIDsMtx.Unlock()
+
+	p.buildIDs[obj.BuildID]++
+	p.fds.add(1)
+}
+
+func (p *Pool) trackClose(obj *ObjectFile) {
+	p.fds.add(-1)
+
+	p.buildIDsMtx.Lock()
+	defer p.buildIDsMtx.Unlock()
+
+	if p.buildIDs[obj.BuildID] <= 1 {
+		delete(p.buildIDs, obj.BuildID)
+		return
+	}
+	p.buildIDs[obj.BuildID]--
+}
+
 // Close closes the pool and all the files in it.
 func (p *Pool) Close() error {
 	// Remove all the cached files from the pool.
 	p.keyCache.Purge()
 	p.objCache.Purge()
-	return nil
+	// Pinned files are retained when purged, close them regardless.
+	return p.closeRetained()
 }
 
 var rgx = regexp.MustCompile(`^/proc/\d+/root`)
@@ -331,10 +801,29 @@ func removeProcPrefix(path string) string {
 	return rgx.ReplaceAllString(path, "")
 }
 
+// buildIDFromELF returns the build ID of the given ELF file, read from its notes.
+// If the file does not have any build ID notes, e.g. it is linked with --build-id=none,
+// a synthetic build ID is derived from the contents of its .text section.
+func buildIDFromELF(ef *elf.File) (string, bool, error) {
+	buildID, err := buildid.FromNotes(ef)
+	if err != nil {
+		return "", false, err
+	}
+	if buildID != "" {
+		return buildID, false, nil
+	}
+	buildID, err = buildid.FromText(ef)
+	if err != nil {
+		return "", false, err
+	}
+	return buildID, true, nil
+}
+
 func cacheKeyFromObject(obj *ObjectFile) cacheKey {
 	return cacheKey{
-		path:    removeProcPrefix(obj.Path),
+		path:    removeProcPrefix(obj.RealPath),
 		buildID: obj.BuildID,
+		size:    obj.Size,
 		modtime: obj.Modtime,
 	}
 }
@@ -354,8 +843,9 @@ func cacheKeyFromFile(f *os.File) (cacheKey, error) {
 		return cacheKey{}, fmt.Errorf("cacheKeyFromFile: failed to get build ID for %s: %w", path, err)
 	}
 	return cacheKey{
-		path:    removeProcPrefix(path),
+		path:    removeProcPrefix(realPath(path)),
 		buildID: buildID,
+		size:    stat.Size(),
 		modtime: stat.ModTime(),
 	}, nil
 }
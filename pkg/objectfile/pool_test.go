@@ -16,7 +16,29 @@
 package objectfile
 
 import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/klauspost/compress/gzip"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/parca-dev/parca-agent/pkg/buildid"
 )
 
 func TestRemoveProcPrefix(t *testing.T) {
@@ -75,3 +97,703 @@ func TestRemoveProcPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestPoolOpenWithoutBuildID(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	// Both binaries are linked with --build-id=none.
+	a, err := objFilePool.Open(filepath.Join("./testdata", "nobuildid-a"))
+	require.NoError(t, err)
+	b, err := objFilePool.Open(filepath.Join("./testdata", "nobuildid-b"))
+	require.NoError(t, err)
+
+	require.NotSame(t, a, b)
+	require.NotEqual(t, a.Path, b.Path)
+	require.Equal(t, filepath.Join("testdata", "nobuildid-a"), a.Path)
+	require.Equal(t, filepath.Join("testdata", "nobuildid-b"), b.Path)
+
+	// Opening the same file again should return the shared reference.
+	again, err := objFilePool.Open(filepath.Join("./testdata", "nobuildid-a"))
+	require.NoError(t, err)
+	require.Same(t, a, again)
+
+	require.True(t, a.SyntheticBuildID)
+	ef, err := a.ELF()
+	require.NoError(t, err)
+	want, err := buildid.FromText(ef)
+	require.NoError(t, err)
+	require.Equal(t, want, a.BuildID)
+
+	// An identical binary at a different path gets the same content-derived build ID.
+	path := filepath.Join(t.TempDir(), "nobuildid-a")
+	copyFile(t, filepath.Join("./testdata", "nobuildid-a"), path)
+	c, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.NotSame(t, a, c)
+	require.True(t, c.SyntheticBuildID)
+	require.Equal(t, a.BuildID, c.BuildID)
+
+	withNote, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.False(t, withNote.SyntheticBuildID)
+}
+
+func TestPoolOpenChangedFile(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	path := filepath.Join(t.TempDir(), "exe")
+	copyFile(t, filepath.Join("./testdata", "fib"), path)
+
+	obj, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, obj.Validate())
+
+	// Replace the binary in place.
+	copyFile(t, filepath.Join("./testdata", "fib-nopie"), path)
+	require.ErrorIs(t, obj.Validate(), ErrFileChanged)
+
+	reopened, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.NotSame(t, obj, reopened)
+	require.NotEqual(t, obj.BuildID, reopened.BuildID)
+
+	// The stale object file should have been evicted and closed.
+	_, err = obj.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestPoolStat(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+	require.Equal(t, PoolStat{}, objFilePool.Stat())
+
+	path := filepath.Join(t.TempDir(), "exe")
+	copyFile(t, filepath.Join("./testdata", "fib"), path)
+
+	_, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	_, err = objFilePool.Open(path)
+	require.NoError(t, err)
+
+	// Replace the binary in place, so it is reopened.
+	copyFile(t, filepath.Join("./testdata", "fib-nopie"), path)
+	reopened, err := objFilePool.Open(path)
+	require.NoError(t, err)
+
+	stat := objFilePool.Stat()
+	// The stale object file is found in the cache before it is validated.
+	require.Equal(t, uint64(2), stat.Hits)
+	require.Equal(t, uint64(2), stat.Misses)
+	require.InDelta(t, 0.5, stat.HitRatio, 0)
+	require.Equal(t, uint64(1), stat.Reopens)
+	// The stale object file has been removed from the cache.
+	require.Equal(t, int64(1), stat.Entries)
+	require.Equal(t, reopened.Size, stat.Size)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0o755))
+}
+
+func TestPoolNewFileWithBuildID(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	f, err := os.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	obj, err := objFilePool.NewFileWithBuildID(f, "deadbeef")
+	require.NoError(t, err)
+	require.Equal(t, "deadbeef", obj.BuildID)
+
+	f, err = os.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	obj, err = objFilePool.NewFileWithBuildID(f, "")
+	require.NoError(t, err)
+	require.Equal(t, "500018e64aeed6f995bac46ae5d81a30159204a5", obj.BuildID)
+}
+
+func TestPoolPreload(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	fib := filepath.Join("./testdata", "fib")
+	missing := filepath.Join("./testdata", "does-not-exist")
+	err := objFilePool.Preload([]string{fib, missing})
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, missing)
+
+	key, ok := objFilePool.keyCache.Get(fib)
+	require.True(t, ok)
+	preloaded, ok := objFilePool.objCache.Get(key)
+	require.True(t, ok)
+
+	obj, err := objFilePool.Open(fib)
+	require.NoError(t, err)
+	require.Same(t, preloaded, obj)
+}
+
+func TestPoolOpenMany(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	fib := filepath.Join("./testdata", "fib")
+	fibNoPIE := filepath.Join("./testdata", "fib-nopie")
+	missing := filepath.Join("./testdata", "does-not-exist")
+	objs, err := objFilePool.OpenMany(context.Background(), []string{fib, fibNoPIE, missing, fib}, 2)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, missing)
+	require.Len(t, objs, 2)
+
+	obj, err := objFilePool.Open(fib)
+	require.NoError(t, err)
+	require.Same(t, objs[fib], obj)
+	require.Equal(t, fibNoPIE, objs[fibNoPIE].Path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	objs, err = objFilePool.OpenMany(ctx, []string{fib, fibNoPIE}, 0)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Nil(t, objs)
+}
+
+func TestPoolOpenInProcess(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	path, err := filepath.Abs(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	obj, err := objFilePool.OpenInProcess(os.Getpid(), path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/proc", strconv.Itoa(os.Getpid()), "root", path), obj.Path)
+
+	// Shared with the file opened at the same path in another mount namespace.
+	again, err := objFilePool.OpenInProcess(os.Getppid(), path)
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+
+	// Doesn't fall back to the path as is, if it can't tell whether the process shares the root of the host.
+	_, err = objFilePool.OpenInProcess(math.MaxInt32, path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = objFilePool.OpenInProcess(os.Getpid(), path+"-does-not-exist")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSharesRoot(t *testing.T) {
+	require.True(t, sharesRoot(os.Getpid()))
+	require.False(t, sharesRoot(math.MaxInt32))
+}
+
+func TestPoolExpiryMultiplier(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, 250*time.Millisecond, WithExpiryMultiplier(2))
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	fib := filepath.Join("./testdata", "fib")
+	obj, err := objFilePool.Open(fib)
+	require.NoError(t, err)
+
+	again, err := objFilePool.Open(fib)
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+
+	// The entry expires after 2 profiling cycles, then the file is opened again.
+	require.Eventually(t, func() bool {
+		again, err = objFilePool.Open(fib)
+		return err == nil && again != obj
+	}, 5*time.Second, 50*time.Millisecond)
+	require.True(t, obj.IsClosed())
+}
+
+func TestPoolCloseDoesNotLeakFDs(t *testing.T) {
+	countFDs := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		require.NoError(t, err)
+		return len(entries)
+	}
+
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	// Warm up, the runtime could open file descriptors of its own on the first open.
+	_, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.NoError(t, objFilePool.Close())
+
+	before := countFDs()
+	for i := 0; i < 10; i++ {
+		objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+		for _, name := range []string{"fib", "fib-nopie", "readelf-sections"} {
+			_, err := objFilePool.Open(filepath.Join("./testdata", name))
+			require.NoError(t, err)
+		}
+		require.NoError(t, objFilePool.Close())
+	}
+	require.Equal(t, before, countFDs())
+}
+
+func TestPoolConcurrentOpen(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	path := filepath.Join("./testdata", "readelf-sections")
+	objs := make([]*ObjectFile, 32)
+	var wg sync.WaitGroup
+	for i := range objs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			obj, err := objFilePool.Open(path)
+			require.NoError(t, err)
+			objs[i] = obj
+		}(i)
+	}
+	wg.Wait()
+
+	for _, obj := range objs {
+		require.Same(t, objs[0], obj)
+	}
+	require.False(t, objs[0].IsClosed())
+}
+
+// slowMissCache delays the misses of the cache, so the concurrent opens of the same file overlap.
+type slowMissCache struct {
+	evictingCache[cacheKey, *ObjectFile]
+}
+
+func (c slowMissCache) Get(key cacheKey) (*ObjectFile, bool) {
+	obj, ok := c.evictingCache.Get(key)
+	if !ok {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return obj, ok
+}
+
+func TestPoolConcurrentOpenSameFile(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	objFilePool.objCache = slowMissCache{objFilePool.objCache}
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exe")
+	copyFile(t, filepath.Join("./testdata", "fib"), path)
+
+	// The opens of different paths aren't shared, but the paths resolve to the same file, so they have the same key.
+	objs := make([]*ObjectFile, 32)
+	var wg sync.WaitGroup
+	for i := range objs {
+		link := filepath.Join(dir, "link-"+strconv.Itoa(i))
+		require.NoError(t, os.Symlink(path, link))
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			obj, err := objFilePool.Open(link)
+			require.NoError(t, err)
+			objs[i] = obj
+		}(i)
+	}
+	wg.Wait()
+
+	for _, obj := range objs {
+		require.Same(t, objs[0], obj)
+	}
+	require.False(t, objs[0].IsClosed())
+
+	stat := objFilePool.Stat()
+	require.Equal(t, int64(1), stat.Entries)
+	require.Equal(t, objs[0].Size, stat.Size)
+	require.Equal(t, uint64(1), stat.Misses)
+	require.Equal(t, uint64(len(objs)-1), stat.Hits)
+	// The files opened by the opens that lost the race are closed.
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.open), 0)
+}
+
+func TestPoolVerboseLogging(t *testing.T) {
+	for _, verbose := range []bool{false, true} {
+		var (
+			buf  bytes.Buffer
+			opts []Option
+		)
+		if verbose {
+			opts = append(opts, WithVerboseLogging())
+		}
+		objFilePool := NewPool(log.NewLogfmtLogger(&buf), prometheus.NewRegistry(), "", 10, time.Minute, opts...)
+
+		_, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+		require.NoError(t, err)
+		_, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+		require.NoError(t, err)
+		require.NoError(t, objFilePool.Close())
+
+		for _, msg := range []string{`msg="object file opened" path=testdata/fib buildid=`, `msg="object file shared"`, `msg="object file closed"`} {
+			if verbose {
+				require.Contains(t, buf.String(), msg)
+			} else {
+				require.NotContains(t, buf.String(), msg)
+			}
+		}
+	}
+}
+
+func TestPoolWithBuildIDFunc(t *testing.T) {
+	var calls int
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute,
+		WithBuildIDFunc(func(_ *os.File, ef *elf.File) (string, error) {
+			calls++
+			return buildid.FromText(ef)
+		}),
+	)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	ef, err := obj.ELF()
+	require.NoError(t, err)
+	want, err := buildid.FromText(ef)
+	require.NoError(t, err)
+	require.Equal(t, want, obj.BuildID)
+	require.False(t, obj.SyntheticBuildID)
+
+	again, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+	require.Equal(t, 1, calls)
+
+	failing := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute,
+		WithBuildIDFunc(func(*os.File, *elf.File) (string, error) {
+			return "", nil
+		}),
+	)
+	t.Cleanup(func() {
+		failing.Close()
+	})
+	_, err = failing.Open(filepath.Join("./testdata", "fib"))
+	require.Error(t, err)
+}
+
+func TestPoolOpenCompressed(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	data, err := os.ReadFile(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	path := filepath.Join(t.TempDir(), "fib.gz")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.Equal(t, "a3e257e3ad8f99654b76013b41eeba07f6d34c2a", obj.BuildID)
+	require.Equal(t, path, obj.Path)
+	require.Equal(t, int64(buf.Len()), obj.Size)
+	require.NoError(t, obj.Validate())
+
+	_, err = obj.ELF()
+	require.NoError(t, err)
+	r, err := obj.Reader()
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), r.Size())
+	name, _, err := obj.SymbolForAddr(0x1149)
+	require.NoError(t, err)
+	require.Equal(t, "fibNaive", name)
+
+	// The decompressed file doesn't leave anything behind.
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	again, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+
+	// Truncated archives are rejected.
+	truncated := filepath.Join(t.TempDir(), "truncated.gz")
+	require.NoError(t, os.WriteFile(truncated, buf.Bytes()[:buf.Len()/2], 0o600))
+	_, err = objFilePool.Open(truncated)
+	require.Error(t, err)
+}
+
+func TestPoolOpenGzipBomb(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	// 64MiB of zeros compress to a few KiB.
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(make([]byte, 64<<20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	bomb := filepath.Join(t.TempDir(), "bomb.gz")
+	require.NoError(t, os.WriteFile(bomb, buf.Bytes(), 0o600))
+
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+	_, err = objFilePool.Open(bomb)
+	require.ErrorIs(t, err, ErrDecompressedTooLarge)
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// The size of the decompressed files is limited too.
+	data, err := os.ReadFile(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	path := filepath.Join(t.TempDir(), "fib.gz")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	limited := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute, WithMaxDecompressedSize(int64(len(data)-1)))
+	t.Cleanup(func() {
+		limited.Close()
+	})
+	_, err = limited.Open(path)
+	require.ErrorIs(t, err, ErrDecompressedTooLarge)
+	_, err = objFilePool.Open(path)
+	require.NoError(t, err)
+}
+
+func TestPoolEvictClosed(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.False(t, obj.IsClosed())
+	require.NoError(t, obj.close())
+	require.True(t, obj.IsClosed())
+
+	// Evicting an object file that is already closed doesn't attempt to close it again.
+	_, err = objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	require.InDelta(t, 1, testutil.ToFloat64(objFilePool.metrics.closeAttempts), 0)
+}
+
+func TestPoolFDExhaustion(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "lru", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	open, limit, err := processFDs()
+	require.NoError(t, err)
+	require.Greater(t, open, int64(0))
+	require.Less(t, float64(open)/float64(limit), fdHighWatermark)
+
+	fib, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.InDelta(t, float64(open)/float64(limit), testutil.ToFloat64(objFilePool.metrics.fdUsage), 0.1)
+	fibNoPIE, err := objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	// The least recently used one is evicted first.
+	_, err = objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+
+	// Evicting an entry frees enough file descriptors.
+	var samples int
+	objFilePool.fds = newFDCounter(func() (int64, int64, error) {
+		samples++
+		return 90, 100, nil
+	})
+	_, err = objFilePool.Open(filepath.Join("./testdata", "nobuildid-a"))
+	require.NoError(t, err)
+	require.True(t, fibNoPIE.IsClosed())
+	require.False(t, fib.IsClosed())
+	// Counted on top of the sample, instead of sampling again.
+	require.InDelta(t, 0.89, testutil.ToFloat64(objFilePool.metrics.fdUsage), 0.001)
+	require.Equal(t, 1, samples)
+
+	// Evicting the whole pool doesn't, the pinned entries are retained.
+	objFilePool.Pin(fib.BuildID)
+	objFilePool.fds = newFDCounter(func() (int64, int64, error) {
+		return 95, 100, nil
+	})
+	_, err = objFilePool.Open(filepath.Join("./testdata", "nobuildid-b"))
+	require.ErrorIs(t, err, ErrTooManyOpenFiles)
+	require.False(t, fib.IsClosed())
+	require.Equal(t, int64(0), objFilePool.Stat().Entries)
+}
+
+func TestPoolKeys(t *testing.T) {
+	// A pool of a single entry, so opening another file evicts the previous one.
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+	require.Empty(t, objFilePool.Keys())
+
+	fib, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.Equal(t, []string{fib.BuildID}, objFilePool.Keys())
+
+	// Evicted, but retained.
+	objFilePool.Pin(fib.BuildID)
+	fibNoPIE, err := objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{fib.BuildID, fibNoPIE.BuildID}, objFilePool.Keys())
+
+	// Closed once unpinned.
+	objFilePool.Unpin(fib.BuildID)
+	require.Equal(t, []string{fibNoPIE.BuildID}, objFilePool.Keys())
+
+	require.NoError(t, objFilePool.Close())
+	require.Empty(t, objFilePool.Keys())
+}
+
+func TestPoolWithTracerProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute,
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))),
+	)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	path := filepath.Join("./testdata", "fib")
+	obj, err := objFilePool.Open(path)
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Len(t, spans, 4)
+
+	open := spans["objectfile.Pool.Open"]
+	require.Contains(t, open.Attributes(), attribute.String("path", path))
+	require.Contains(t, open.Attributes(), attribute.String("buildid", obj.BuildID))
+	newFile := spans["objectfile.Pool.NewFile"]
+	require.Equal(t, open.SpanContext().SpanID(), newFile.Parent().SpanID())
+	for _, name := range []string{"elf.NewFile", "buildid.BuildID"} {
+		require.Equal(t, newFile.SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+	}
+
+	_, err = objFilePool.Open(filepath.Join("./testdata", "does-not-exist"))
+	require.Error(t, err)
+	failed := recorder.Ended()[len(recorder.Ended())-1]
+	require.Equal(t, "objectfile.Pool.Open", failed.Name())
+	require.Equal(t, codes.Error, failed.Status().Code)
+}
+
+func TestPoolOpenTruncated(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	// The first 8000 bytes of fib, the section headers are missing.
+	_, err := objFilePool.Open(filepath.Join("./testdata", "truncated"))
+	require.ErrorIs(t, err, ErrTruncatedELF)
+
+	// Still being written: not cached, so it can be opened once it's complete.
+	data, err := os.ReadFile(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "fib")
+	require.NoError(t, os.WriteFile(path, data[:len(data)/2], 0o600))
+	_, err = objFilePool.Open(path)
+	require.ErrorIs(t, err, ErrTruncatedELF)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	_, err = objFilePool.Open(path)
+	require.NoError(t, err)
+
+	// The headers are complete, but the data of the sections is missing.
+	ef, err := elf.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ef.Close()
+	})
+	require.NoError(t, checkTruncated(ef, int64(len(data))))
+	require.ErrorIs(t, checkTruncated(ef, 10_000), ErrTruncatedELF)
+}
+
+func TestPoolOpenSymlink(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	data, err := os.ReadFile(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "libfib.so.3")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	link := filepath.Join(dir, "libfib.so")
+	require.NoError(t, os.Symlink("libfib.so.3", link))
+
+	obj, err := objFilePool.Open(path)
+	require.NoError(t, err)
+	require.Equal(t, path, obj.RealPath)
+
+	linked, err := objFilePool.Open(link)
+	require.NoError(t, err)
+	require.Same(t, obj, linked)
+}
+
+func TestEvalSymlinksIn(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usr", "lib"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "usr", "lib", "libssl.so.3"), nil, 0o600))
+	// Absolute links are resolved in the root, not on the host.
+	require.NoError(t, os.Symlink("/usr/lib/libssl.so.3", filepath.Join(root, "usr", "lib", "libssl.so")))
+	require.NoError(t, os.Symlink("usr/lib", filepath.Join(root, "lib")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(root, "loop")))
+
+	got, err := evalSymlinksIn(root, "/lib/libssl.so")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "usr", "lib", "libssl.so.3"), got)
+
+	got, err = evalSymlinksIn(root, "/lib/../lib/./libssl.so.3")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(root, "usr", "lib", "libssl.so.3"), got)
+
+	_, err = evalSymlinksIn(root, "/loop")
+	require.ErrorIs(t, err, errTooManySymlinks)
+
+	_, err = evalSymlinksIn(root, "/lib/missing.so")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks is the maximum number of symbolic links followed to resolve a path, as with Linux.
+const maxSymlinks = 40
+
+var errTooManySymlinks = errors.New("too many levels of symbolic links")
+
+// realPath resolves the symbolic links of the given path, e.g. of versioned libraries like libssl.so -> libssl.so.3,
+// so the same file reached through different links shares an entry in the pool.
+// The links of paths under /proc/<pid>/root are resolved in the root of the process,
+// as the absolute links would otherwise point to the files of the host.
+// If the path can't be resolved, it is returned as is.
+func realPath(path string) string {
+	var (
+		resolved string
+		err      error
+	)
+	if root := rgx.FindString(path); root != "" {
+		resolved, err = evalSymlinksIn(root, strings.TrimPrefix(path, root))
+	} else {
+		resolved, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+// evalSymlinksIn is like filepath.EvalSymlinks, but resolves the path as if root was the root directory.
+// The returned path includes root.
+func evalSymlinksIn(root, path string) (string, error) {
+	var (
+		resolved = "/"
+		pending  = strings.Split(path, "/")
+		links    int
+	)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		fi, err := os.Lstat(root + next)
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{Op: "lstat", Path: root + path, Err: errTooManySymlinks}
+		}
+		target, err := os.Readlink(root + next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return root + resolved, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"time"
+)
+
+// RetentionPolicy decides whether the object files evicted from the pool's cache, e.g. because they expired
+// or the pool is full, are closed, or kept open so they can be shared again without being reopened.
+// It is consulted when an object file is evicted, when its build ID is unpinned,
+// and for the object files it kept open, every time a file is opened from disk.
+// It is called with the pool's locks held, so it must not call the pool.
+type RetentionPolicy interface {
+	// ShouldEvict reports whether the evicted object file should be closed.
+	// lastAccess is the last time the object file was opened or shared by the pool,
+	// and pins is the number of pins of its build ID (see Pool.Pin).
+	ShouldEvict(obj *ObjectFile, lastAccess time.Time, pins int) bool
+}
+
+// RetentionPolicyFunc is a function that implements RetentionPolicy.
+type RetentionPolicyFunc func(obj *ObjectFile, lastAccess time.Time, pins int) bool
+
+// ShouldEvict calls fn.
+func (fn RetentionPolicyFunc) ShouldEvict(obj *ObjectFile, lastAccess time.Time, pins int) bool {
+	return fn(obj, lastAccess, pins)
+}
+
+// RetainPinned is the default RetentionPolicy of the pool: the evicted object files are closed,
+// unless their build ID is pinned.
+var RetainPinned RetentionPolicy = RetentionPolicyFunc(func(_ *ObjectFile, _ time.Time, pins int) bool {
+	return pins == 0
+})
+
+// RetainRecentlyAccessed is a RetentionPolicy that keeps the evicted object files open for as long as
+// they have been accessed within the given duration, or their build ID is pinned,
+// e.g. so the files of long-running processes are not reopened in each profiling cycle,
+// while the files of the processes that exited are closed.
+func RetainRecentlyAccessed(d time.Duration) RetentionPolicy {
+	return RetentionPolicyFunc(func(_ *ObjectFile, lastAccess time.Time, pins int) bool {
+		return pins == 0 && time.Since(lastAccess) > d
+	})
+}
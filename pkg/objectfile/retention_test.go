@@ -0,0 +1,76 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestPoolRetentionPolicy(t *testing.T) {
+	keep := atomic.NewBool(true)
+	// A pool of a single entry, so opening another file evicts the previous one.
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute,
+		WithRetentionPolicy(RetentionPolicyFunc(func(_ *ObjectFile, _ time.Time, pins int) bool {
+			return pins == 0 && !keep.Load()
+		})),
+	)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	retained, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	_, err = objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+
+	// Evicted, but kept open by the policy.
+	_, err = retained.ELF()
+	require.NoError(t, err)
+	reopened, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.Same(t, retained, reopened)
+
+	other, err := objFilePool.Open(filepath.Join("./testdata", "fib-nopie"))
+	require.NoError(t, err)
+	_, err = retained.ELF()
+	require.NoError(t, err)
+
+	// The retained files are closed once the policy doesn't keep them anymore.
+	keep.Store(false)
+	_, err = objFilePool.Open(filepath.Join("./testdata", "runpath"))
+	require.NoError(t, err)
+	_, err = retained.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+	_, err = other.ELF()
+	require.ErrorIs(t, err, ErrAlreadyClosed)
+}
+
+func TestRetainRecentlyAccessed(t *testing.T) {
+	rp := RetainRecentlyAccessed(time.Minute)
+
+	require.False(t, rp.ShouldEvict(nil, time.Now(), 0))
+	require.True(t, rp.ShouldEvict(nil, time.Now().Add(-time.Hour), 0))
+	require.False(t, rp.ShouldEvict(nil, time.Now().Add(-time.Hour), 1))
+
+	require.True(t, RetainPinned.ShouldEvict(nil, time.Now(), 0))
+	require.False(t, RetainPinned.ShouldEvict(nil, time.Now(), 1))
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"go.uber.org/atomic"
+	"golang.org/x/sys/unix"
+
+	"github.com/parca-dev/parca-agent/pkg/procmem"
+)
+
+const vdsoPath = "[vdso]"
+
+var ErrNoVDSO = errors.New("process has no vdso mapping")
+
+// OpenVDSO opens the vDSO mapped into the memory of the process with the given PID.
+// The vDSO is not backed by a file on disk, so it is read from the memory of the process.
+// It is cached under its build ID, which is derived from its contents,
+// so all the processes that map the same vDSO share a single object file.
+func (p *Pool) OpenVDSO(pid int) (_ *ObjectFile, err error) { //nolint:nonamedreturns
+	defer func() {
+		if err != nil {
+			p.metrics.opened.WithLabelValues(lvError).Inc()
+		}
+	}()
+
+	data, err := readVDSO(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	ef, err := elfNewFile(bytes.NewReader(data))
+	if err != nil {
+		p.metrics.openErrors.WithLabelValues(lvNotELF).Inc()
+		return nil, fmt.Errorf("error opening vdso of %d: %w", pid, err)
+	}
+	// Falls back to the hash of the .text section, if there is no build ID note.
+	buildID, synthetic, err := buildIDFromELF(ef)
+	if err != nil {
+		p.metrics.openErrors.WithLabelValues(lvBuildID).Inc()
+		return nil, fmt.Errorf("failed to get build ID of vdso of %d: %w", pid, err)
+	}
+
+	key := cacheKey{
+		path:    vdsoPath,
+		buildID: buildID,
+		size:    int64(len(data)),
+	}
+	if obj, err := p.get(key); err == nil {
+		return obj, nil
+	}
+
+	// Back the object file with an anonymous file, so it can be read like any other object file.
+	f, err := memfd(vdsoPath, data)
+	if err != nil {
+		return nil, err
+	}
+	ef, err = elfNewFile(f)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("error opening vdso of %d: %w", pid, err), f.Close())
+	}
+
+	obj := &ObjectFile{
+		p: p,
+
+		BuildID:          buildID,
+		SyntheticBuildID: synthetic,
+		Path:             vdsoPath,
+		RealPath:         vdsoPath,
+
+		file:       f,
+		openedAt:   time.Now(),
+		lastAccess: atomic.NewTime(time.Now()),
+		Size:       int64(len(data)),
+		fileSize:   int64(len(data)),
+		closed:     atomic.NewBool(false),
+		elf:        ef,
+	}
+	p.metrics.open.Inc()
+	p.trackOpen(obj)
+
+	if cached, added := p.getOrAdd(key, obj); !added {
+		// The vdso has been opened concurrently, e.g. for another process.
+		if err := obj.close(); err != nil {
+			level.Debug(p.logger).Log("msg", "failed to close object file", "path", vdsoPath, "err", err)
+		}
+		return cached, nil
+	}
+	p.misses.Inc()
+	p.metrics.opened.WithLabelValues(lvSuccess).Inc()
+	p.logEvent("object file opened", obj)
+	return obj, nil
+}
+
+// readVDSO reads the vdso mapping from the memory of the process with the given PID.
+func readVDSO(pid int) ([]byte, error) {
+	maps, err := os.Open(path.Join("/proc", strconv.Itoa(pid), "maps"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open maps of %d: %w", pid, err)
+	}
+	defer maps.Close()
+
+	start, end, err := findVDSO(maps)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find vdso of %d: %w", pid, err)
+	}
+
+	data, err := procmem.ReadProcessMemory(pid, start, int(end-start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vdso: %w", err)
+	}
+	return data, nil
+}
+
+// findVDSO returns the address range of the vdso mapping from the contents of /proc/[pid]/maps.
+func findVDSO(r io.Reader) (uint64, uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[5] != vdsoPath {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return 0, 0, fmt.Errorf("invalid address range %q", fields[0])
+		}
+		startAddr, err := strconv.ParseUint(start, 16, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid start address: %w", err)
+		}
+		endAddr, err := strconv.ParseUint(end, 16, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid end address: %w", err)
+		}
+		if endAddr <= startAddr {
+			return 0, 0, fmt.Errorf("invalid address range %q", fields[0])
+		}
+		return startAddr, endAddr, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, ErrNoVDSO
+}
+
+// memfd returns an anonymous, memory backed file with the given contents.
+func memfd(name string, data []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate(name, unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create memfd: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if _, err := f.Write(data); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to write memfd: %w", err), f.Close())
+	}
+	return f, nil
+}
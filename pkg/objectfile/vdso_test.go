@@ -0,0 +1,96 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package objectfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindVDSO(t *testing.T) {
+	start, end, err := findVDSO(strings.NewReader(`7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0                          [stack]
+7ffc000fc000-7ffc00100000 r--p 00000000 00:00 0                          [vvar]
+7ffc00100000-7ffc00102000 r-xp 00000000 00:00 0                          [vdso]
+`))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x7ffc00100000), start)
+	require.Equal(t, uint64(0x7ffc00102000), end)
+
+	_, _, err = findVDSO(strings.NewReader("00400000-00401000 r--p 00000000 fd:01 10 /usr/bin/app\n"))
+	require.ErrorIs(t, err, ErrNoVDSO)
+}
+
+func TestPoolOpenVDSO(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 10, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	obj, err := objFilePool.OpenVDSO(os.Getpid())
+	require.NoError(t, err)
+	require.Equal(t, "[vdso]", obj.Path)
+	require.NotEmpty(t, obj.BuildID)
+
+	ef, err := obj.ELF()
+	require.NoError(t, err)
+	syms, err := ef.DynamicSymbols()
+	require.NoError(t, err)
+	var found bool
+	for _, sym := range syms {
+		if strings.HasPrefix(sym.Name, "__vdso_clock_gettime") {
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	again, err := objFilePool.OpenVDSO(os.Getpid())
+	require.NoError(t, err)
+	require.Same(t, obj, again)
+}
+
+func TestPoolStatVDSO(t *testing.T) {
+	objFilePool := NewPool(log.NewNopLogger(), prometheus.NewRegistry(), "", 1, time.Minute)
+	t.Cleanup(func() {
+		objFilePool.Close()
+	})
+
+	vdso, err := objFilePool.OpenVDSO(os.Getpid())
+	require.NoError(t, err)
+	_, err = objFilePool.OpenVDSO(os.Getpid())
+	require.NoError(t, err)
+
+	stat := objFilePool.Stat()
+	require.Equal(t, uint64(1), stat.Hits)
+	require.Equal(t, uint64(1), stat.Misses)
+	require.Equal(t, int64(1), stat.Entries)
+	require.Equal(t, vdso.Size, stat.Size)
+
+	// The pool holds a single object file, so the vdso is evicted.
+	obj, err := objFilePool.Open(filepath.Join("./testdata", "fib"))
+	require.NoError(t, err)
+	require.True(t, vdso.IsClosed())
+
+	stat = objFilePool.Stat()
+	require.Equal(t, uint64(2), stat.Misses)
+	require.Equal(t, int64(1), stat.Entries)
+	require.Equal(t, obj.Size, stat.Size)
+}
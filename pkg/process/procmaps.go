@@ -0,0 +1,213 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+const deletedSuffix = " (deleted)"
+
+// MapEntry is a single line of /proc/[pid]/maps.
+// See https://man7.org/linux/man-pages/man5/proc.5.html.
+type MapEntry struct {
+	StartAddr uintptr
+	EndAddr   uintptr
+	Perms     procfs.ProcMapPermissions
+	Offset    int64
+	DevMajor  uint32
+	DevMinor  uint32
+	Inode     uint64
+	// Pathname is the file backing the mapping, or a pseudo-path like [stack], [heap] or [vdso].
+	// It is empty for anonymous mappings.
+	Pathname string
+	// Deleted is true if the file backing the mapping has been deleted.
+	// The " (deleted)" suffix is removed from Pathname.
+	Deleted bool
+}
+
+// ParseProcMaps parses the contents of /proc/[pid]/maps.
+// Unlike procfs, it keeps pathnames that contain spaces intact.
+func ParseProcMaps(r io.Reader) ([]MapEntry, error) {
+	var entries []MapEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		entry, err := parseMapEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("malformed maps line %q: %w", text, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read maps: %w", err)
+	}
+	return entries, nil
+}
+
+// parseMapEntry parses a single line of the maps file, e.g.:
+//
+//	7f6a0b028000-7f6a0b1bd000 r-xp 00028000 fd:01 1835099                    /usr/lib/libc.so.6
+func parseMapEntry(line string) (MapEntry, error) {
+	var (
+		fields [5]string
+		rest   = line
+	)
+	for i := range fields {
+		rest = strings.TrimLeft(rest, " \t")
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		fields[i], rest = rest[:end], rest[end:]
+		if fields[i] == "" {
+			return MapEntry{}, fmt.Errorf("expected at least %d fields", len(fields))
+		}
+	}
+
+	var entry MapEntry
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return MapEntry{}, fmt.Errorf("invalid address range %q", fields[0])
+	}
+	startAddr, err := strconv.ParseUint(start, 16, 64)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid start address: %w", err)
+	}
+	endAddr, err := strconv.ParseUint(end, 16, 64)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid end address: %w", err)
+	}
+	entry.StartAddr, entry.EndAddr = uintptr(startAddr), uintptr(endAddr)
+
+	if len(fields[1]) != 4 {
+		return MapEntry{}, fmt.Errorf("invalid permissions %q", fields[1])
+	}
+	entry.Perms = procfs.ProcMapPermissions{
+		Read:    fields[1][0] == 'r',
+		Write:   fields[1][1] == 'w',
+		Execute: fields[1][2] == 'x',
+		Shared:  fields[1][3] == 's',
+		Private: fields[1][3] == 'p',
+	}
+
+	offset, err := strconv.ParseInt(fields[2], 16, 64)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid offset: %w", err)
+	}
+	entry.Offset = offset
+
+	major, minor, ok := strings.Cut(fields[3], ":")
+	if !ok {
+		return MapEntry{}, fmt.Errorf("invalid device %q", fields[3])
+	}
+	devMajor, err := strconv.ParseUint(major, 16, 32)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid device major: %w", err)
+	}
+	devMinor, err := strconv.ParseUint(minor, 16, 32)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid device minor: %w", err)
+	}
+	entry.DevMajor, entry.DevMinor = uint32(devMajor), uint32(devMinor)
+
+	inode, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		return MapEntry{}, fmt.Errorf("invalid inode: %w", err)
+	}
+	entry.Inode = inode
+
+	// The pathname is padded with spaces, and could contain spaces itself.
+	entry.Pathname = strings.TrimLeft(rest, " \t")
+	if strings.HasSuffix(entry.Pathname, deletedSuffix) {
+		entry.Pathname = strings.TrimSuffix(entry.Pathname, deletedSuffix)
+		entry.Deleted = true
+	}
+	return entry, nil
+}
+
+// mapKey identifies a file-backed mapping regardless of where it is mapped in the address space.
+type mapKey struct {
+	pathname string
+	offset   int64
+	size     uintptr
+	devMajor uint32
+	devMinor uint32
+	inode    uint64
+}
+
+func newMapKey(e MapEntry) mapKey {
+	return mapKey{
+		pathname: e.Pathname,
+		offset:   e.Offset,
+		size:     e.EndAddr - e.StartAddr,
+		devMajor: e.DevMajor,
+		devMinor: e.DevMinor,
+		inode:    e.Inode,
+	}
+}
+
+// isFileBacked reports whether the mapping is backed by a file,
+// as opposed to anonymous mappings and pseudo-paths like [heap], [stack] or [vdso].
+func (e MapEntry) isFileBacked() bool {
+	return e.Pathname != "" && !strings.HasPrefix(e.Pathname, "[")
+}
+
+// DiffMaps compares two snapshots of /proc/[pid]/maps of the same process, e.g. to detect the shared libraries
+// loaded with dlopen since the last snapshot, and returns the file-backed mappings that are only in after and only in before.
+// Mappings are the same if they map the same range of the same file, i.e. they have the same path, device, inode,
+// offset and size, regardless of their addresses, so the same mapping at another address is not reported as a change.
+// A file that is replaced at the same path has a different inode, so it's reported as removed and added.
+// Anonymous mappings and pseudo-paths (e.g. [heap]) are ignored, as they change all the time.
+func DiffMaps(before, after []MapEntry) (added, removed []MapEntry) { //nolint:nonamedreturns
+	// The same range of a file can be mapped more than once.
+	counts := map[mapKey]int{}
+	for _, e := range before {
+		if e.isFileBacked() {
+			counts[newMapKey(e)]++
+		}
+	}
+	for _, e := range after {
+		if !e.isFileBacked() {
+			continue
+		}
+		k := newMapKey(e)
+		if counts[k] > 0 {
+			counts[k]--
+			continue
+		}
+		added = append(added, e)
+	}
+	for _, e := range before {
+		if !e.isFileBacked() {
+			continue
+		}
+		k := newMapKey(e)
+		if counts[k] > 0 {
+			counts[k]--
+			removed = append(removed, e)
+		}
+	}
+	return added, removed
+}
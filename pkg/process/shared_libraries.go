@@ -0,0 +1,79 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+)
+
+// SharedLibrary is an executable file mapped into the memory of a process,
+// either the main executable or one of the shared libraries it loaded.
+type SharedLibrary struct {
+	StartAddr uintptr
+	EndAddr   uintptr
+	Offset    int64
+	Inode     uint64
+	// Path is the path of the file in the mount namespace of the process.
+	Path string
+}
+
+// SharedLibraries returns the distinct files of the executable, file-backed mappings of a process.
+// Anonymous and special mappings (e.g. [vdso]) are skipped.
+// If a file is mapped multiple times, only its first (lowest) mapping is returned.
+func SharedLibraries(pid int) ([]SharedLibrary, error) {
+	f, err := os.Open(path.Join("/proc", strconv.Itoa(pid), "maps"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.Join(ErrProcNotFound, fmt.Errorf("failed to open proc %d: %w", pid, err))
+		}
+		return nil, fmt.Errorf("failed to open proc maps of %d: %w", pid, err)
+	}
+	defer f.Close()
+
+	maps, err := ParseProcMaps(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proc maps of %d: %w", pid, err)
+	}
+	return sharedLibraries(maps), nil
+}
+
+func sharedLibraries(maps []MapEntry) []SharedLibrary {
+	var (
+		libs = make([]SharedLibrary, 0, len(maps))
+		seen = make(map[string]struct{}, len(maps))
+	)
+	for _, m := range maps {
+		if !m.Perms.Execute || m.Deleted || !doesReferToFile(m.Pathname) {
+			continue
+		}
+		if _, ok := seen[m.Pathname]; ok {
+			continue
+		}
+		seen[m.Pathname] = struct{}{}
+		libs = append(libs, SharedLibrary{
+			StartAddr: m.StartAddr,
+			EndAddr:   m.EndAddr,
+			Offset:    m.Offset,
+			Inode:     m.Inode,
+			Path:      m.Pathname,
+		})
+	}
+	return libs
+}
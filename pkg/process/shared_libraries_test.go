@@ -0,0 +1,110 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package process
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/procfs"
+	"github.com/stretchr/testify/require"
+)
+
+const procMaps = `00400000-00401000 r--p 00000000 fd:01 10                                 /usr/bin/app
+00401000-00402000 r-xp 00001000 fd:01 10                                 /usr/bin/app
+00402000-00403000 r-xp 00002000 fd:01 10                                 /usr/bin/app
+01a3c000-01a5d000 rw-p 00000000 00:00 0                                  [heap]
+7f0000000000-7f0000001000 r-xp 00000000 00:00 0 
+7f0000028000-7f00001bd000 r-xp 00028000 fd:01 20                         /usr/lib/libc.so.6
+7f0000200000-7f0000201000 r-xp 00000000 fd:01 30                         /tmp/old.so (deleted)
+7f0000300000-7f0000301000 r-xp 00000000 fd:01 40                         /opt/my app/lib plugin.so
+7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0                          [stack]
+7ffc00100000-7ffc00102000 r-xp 00000000 00:00 0                          [vdso]
+`
+
+func TestParseProcMaps(t *testing.T) {
+	entries, err := ParseProcMaps(strings.NewReader(procMaps))
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+
+	require.Equal(t, MapEntry{
+		StartAddr: 0x7f0000028000,
+		EndAddr:   0x7f00001bd000,
+		Perms:     procfs.ProcMapPermissions{Read: true, Execute: true, Private: true},
+		Offset:    0x28000,
+		DevMajor:  0xfd,
+		DevMinor:  0x1,
+		Inode:     20,
+		Pathname:  "/usr/lib/libc.so.6",
+	}, entries[5])
+	require.Equal(t, "[heap]", entries[3].Pathname)
+	require.Equal(t, procfs.ProcMapPermissions{Read: true, Write: true, Private: true}, entries[3].Perms)
+	require.Equal(t, "", entries[4].Pathname)
+	require.Equal(t, "/tmp/old.so", entries[6].Pathname)
+	require.True(t, entries[6].Deleted)
+	require.Equal(t, "/opt/my app/lib plugin.so", entries[7].Pathname)
+	require.Equal(t, "[vdso]", entries[9].Pathname)
+
+	_, err = ParseProcMaps(strings.NewReader("00400000-00401000 r--p 00000000 fd:01\n"))
+	require.Error(t, err)
+	_, err = ParseProcMaps(strings.NewReader("00400000 r--p 00000000 fd:01 10 /usr/bin/app\n"))
+	require.Error(t, err)
+}
+
+func TestDiffMaps(t *testing.T) {
+	old, err := ParseProcMaps(strings.NewReader(procMaps))
+	require.NoError(t, err)
+
+	added, removed := DiffMaps(old, old)
+	require.Empty(t, added)
+	require.Empty(t, removed)
+
+	// A library is loaded, another one is unloaded, the heap grew and libc moved.
+	loaded, err := ParseProcMaps(strings.NewReader(`00400000-00401000 r--p 00000000 fd:01 10                                 /usr/bin/app
+00401000-00402000 r-xp 00001000 fd:01 10                                 /usr/bin/app
+00402000-00403000 r-xp 00002000 fd:01 10                                 /usr/bin/app
+01a3c000-01a7d000 rw-p 00000000 00:00 0                                  [heap]
+7f1000028000-7f10001bd000 r-xp 00028000 fd:01 20                         /usr/lib/libc.so.6
+7f0000200000-7f0000201000 r-xp 00000000 fd:01 30                         /tmp/old.so (deleted)
+7f0000400000-7f0000402000 r-xp 00001000 fd:01 50                         /usr/lib/libplugin.so
+7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0                          [stack]
+7ffc00100000-7ffc00102000 r-xp 00000000 00:00 0                          [vdso]
+`))
+	require.NoError(t, err)
+
+	added, removed = DiffMaps(old, loaded)
+	require.Len(t, added, 1)
+	require.Equal(t, "/usr/lib/libplugin.so", added[0].Pathname)
+	require.Len(t, removed, 1)
+	require.Equal(t, "/opt/my app/lib plugin.so", removed[0].Pathname)
+
+	// A file replaced at the same path is a different mapping.
+	replaced := append([]MapEntry{}, old...)
+	replaced[5].Inode = 21
+	added, removed = DiffMaps(old, replaced)
+	require.Equal(t, []MapEntry{replaced[5]}, added)
+	require.Equal(t, []MapEntry{old[5]}, removed)
+}
+
+func TestSharedLibraries(t *testing.T) {
+	entries, err := ParseProcMaps(strings.NewReader(procMaps))
+	require.NoError(t, err)
+
+	require.Equal(t, []SharedLibrary{
+		{StartAddr: 0x401000, EndAddr: 0x402000, Offset: 0x1000, Inode: 10, Path: "/usr/bin/app"},
+		{StartAddr: 0x7f0000028000, EndAddr: 0x7f00001bd000, Offset: 0x28000, Inode: 20, Path: "/usr/lib/libc.so.6"},
+		{StartAddr: 0x7f0000300000, EndAddr: 0x7f0000301000, Offset: 0, Inode: 40, Path: "/opt/my app/lib plugin.so"},
+	}, sharedLibraries(entries))
+}
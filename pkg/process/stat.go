@@ -0,0 +1,120 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package process
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrProcessGone is returned when the process exits while its files are being read.
+var ErrProcessGone = errors.New("process is gone")
+
+// ProcStat are the fields of /proc/[pid]/stat that are relevant to profiling.
+// See https://man7.org/linux/man-pages/man5/proc.5.html.
+type ProcStat struct {
+	PID int
+	// Comm is the name of the executable, without the parentheses, truncated to 15 characters by the kernel.
+	Comm  string
+	State string
+	PPID  int
+	// UTime and STime are the CPU times spent in user and kernel mode, in clock ticks.
+	UTime uint64
+	STime uint64
+	// NumThreads is the number of threads of the process.
+	NumThreads int
+	// StartTime is the time the process started after system boot, in clock ticks.
+	StartTime uint64
+}
+
+// ProcessStat reads the fields of /proc/[pid]/stat that are relevant to profiling, e.g. to normalize the CPU time.
+// It returns ErrProcessGone if the process has exited.
+func ProcessStat(pid int) (ProcStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		// The reads fail with ESRCH if the process exits after the file is opened.
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ESRCH) {
+			return ProcStat{}, errors.Join(ErrProcessGone, err)
+		}
+		return ProcStat{}, fmt.Errorf("failed to read stat of process %d: %w", pid, err)
+	}
+	if len(data) == 0 {
+		return ProcStat{}, fmt.Errorf("%w: stat of process %d is empty", ErrProcessGone, pid)
+	}
+	return parseProcStat(data)
+}
+
+// parseProcStat parses the contents of /proc/[pid]/stat, e.g.:
+//
+//	1234 (my (weird) app) S 1 1234 1234 0 -1 4194560 ...
+//
+// The comm field can contain spaces and parentheses, so it spans up to the last closing parenthesis.
+func parseProcStat(data []byte) (ProcStat, error) {
+	line := string(bytes.TrimSpace(data))
+	open := strings.IndexByte(line, '(')
+	closing := strings.LastIndexByte(line, ')')
+	if open < 0 || closing < open {
+		return ProcStat{}, fmt.Errorf("malformed stat %q: no comm field", line)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat pid: %w", err)
+	}
+	// The fields after the comm field, starting with the 3rd field, state.
+	fields := strings.Fields(line[closing+1:])
+	const (
+		stateField      = 3
+		ppidField       = 4
+		utimeField      = 14
+		stimeField      = 15
+		numThreadsField = 20
+		startTimeField  = 22
+	)
+	if len(fields) < startTimeField-stateField+1 {
+		return ProcStat{}, fmt.Errorf("malformed stat %q: %d fields after comm", line, len(fields))
+	}
+	field := func(n int) string {
+		return fields[n-stateField]
+	}
+
+	stat := ProcStat{
+		PID:   pid,
+		Comm:  line[open+1 : closing],
+		State: field(stateField),
+	}
+	if stat.PPID, err = strconv.Atoi(field(ppidField)); err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat ppid: %w", err)
+	}
+	if stat.UTime, err = strconv.ParseUint(field(utimeField), 10, 64); err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat utime: %w", err)
+	}
+	if stat.STime, err = strconv.ParseUint(field(stimeField), 10, 64); err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat stime: %w", err)
+	}
+	if stat.NumThreads, err = strconv.Atoi(field(numThreadsField)); err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat num_threads: %w", err)
+	}
+	if stat.StartTime, err = strconv.ParseUint(field(startTimeField), 10, 64); err != nil {
+		return ProcStat{}, fmt.Errorf("malformed stat starttime: %w", err)
+	}
+	return stat, nil
+}
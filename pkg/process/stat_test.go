@@ -0,0 +1,53 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package process
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat, err := parseProcStat([]byte("1234 (my (weird) app) S 1 1234 1234 0 -1 4194560 2049 0 3 0 150 42 0 0 20 0 7 0 98765 12345678 910 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0\n"))
+	require.NoError(t, err)
+	require.Equal(t, ProcStat{
+		PID:        1234,
+		Comm:       "my (weird) app",
+		State:      "S",
+		PPID:       1,
+		UTime:      150,
+		STime:      42,
+		NumThreads: 7,
+		StartTime:  98765,
+	}, stat)
+
+	_, err = parseProcStat([]byte("1234 (app S 1"))
+	require.Error(t, err)
+	_, err = parseProcStat([]byte("1234 (app) S 1 1234"))
+	require.Error(t, err)
+}
+
+func TestProcessStat(t *testing.T) {
+	stat, err := ProcessStat(os.Getpid())
+	require.NoError(t, err)
+	require.Equal(t, os.Getpid(), stat.PID)
+	require.Equal(t, os.Getppid(), stat.PPID)
+	require.Positive(t, stat.NumThreads)
+
+	_, err = ProcessStat(-1)
+	require.ErrorIs(t, err, ErrProcessGone)
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package procmem reads the memory of other processes.
+package procmem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrProcessNotFound  = errors.New("process not found")
+	ErrPermissionDenied = errors.New("permission denied to read process memory")
+)
+
+// ReadProcessMemory reads size bytes at the given address from the memory of the process with the given PID.
+// It uses process_vm_readv(2), and falls back to reading /proc/[pid]/mem if the syscall is not available
+// or not permitted. Partial reads are retried for the remaining bytes; if the range is not fully mapped,
+// an error wrapping io.ErrUnexpectedEOF is returned.
+// It returns ErrProcessNotFound if the process is gone and ErrPermissionDenied if its memory can't be read.
+func ReadProcessMemory(pid int, addr uint64, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+	buf := make([]byte, size)
+	if size == 0 {
+		return buf, nil
+	}
+
+	err := readv(pid, addr, buf)
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) {
+		err = readProcMem(pid, addr, buf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d bytes at 0x%x from process %d: %w", size, addr, pid, classify(err))
+	}
+	return buf, nil
+}
+
+func readv(pid int, addr uint64, buf []byte) error {
+	for read := 0; read < len(buf); {
+		local := []unix.Iovec{{Base: &buf[read]}}
+		local[0].SetLen(len(buf) - read)
+		remote := []unix.RemoteIovec{{Base: uintptr(addr) + uintptr(read), Len: len(buf) - read}}
+
+		n, err := unix.ProcessVMReadv(pid, local, remote, 0)
+		if err != nil {
+			if read > 0 && errors.Is(err, unix.EFAULT) {
+				// The rest of the range is not mapped.
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		read += n
+	}
+	return nil
+}
+
+func readProcMem(pid int, addr uint64, buf []byte) error {
+	f, err := os.Open(path.Join("/proc", strconv.Itoa(pid), "mem"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// ReadAt keeps reading until the buffer is full.
+	if _, err := f.ReadAt(buf, int64(addr)); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, unix.EIO) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func classify(err error) error {
+	switch {
+	case errors.Is(err, unix.ESRCH), errors.Is(err, fs.ErrNotExist):
+		return errors.Join(ErrProcessNotFound, err)
+	case errors.Is(err, unix.EPERM), errors.Is(err, fs.ErrPermission):
+		return errors.Join(ErrPermissionDenied, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 The Parca Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package procmem
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProcessMemory(t *testing.T) {
+	want := []byte("parca-agent reads process memory")
+	addr := uint64(uintptr(unsafe.Pointer(&want[0])))
+
+	got, err := ReadProcessMemory(os.Getpid(), addr, len(want))
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	got = make([]byte, len(want))
+	require.NoError(t, readProcMem(os.Getpid(), addr, got))
+	require.Equal(t, want, got)
+	runtime.KeepAlive(want)
+
+	_, err = ReadProcessMemory(os.Getpid(), 0, 8)
+	require.Error(t, err)
+
+	_, err = ReadProcessMemory(os.Getpid(), addr, -1)
+	require.Error(t, err)
+
+	// PIDs are limited to 2^22.
+	_, err = ReadProcessMemory(1<<30, addr, len(want))
+	require.ErrorIs(t, err, ErrProcessNotFound)
+}